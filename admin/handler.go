@@ -0,0 +1,344 @@
+// Package admin provides an HTTP handler that exposes the live configuration
+// of an application, allowing to inspect it at runtime. Example:
+//
+//	parsed, err := proteus.MustParse(&params, proteus.WithHistory(100))
+//	if err != nil {
+//		parsed.WriteError(os.Stderr, err)
+//		os.Exit(1)
+//	}
+//
+//	http.Handle("/debug/config", admin.Handler(parsed))
+//
+// The handler responds with JSON by default. Text output can be requested
+// with the "format=text" query parameter, or with the "Accept: text/plain"
+// header.
+//
+// Values of secret parameters are always redacted.
+//
+// Optionally, the handler can allow overriding parameter values at runtime
+// (see proteus.Parsed.Override). This must be explicitly enabled with the
+// WithOverrides option:
+//
+//	admin.Handler(parsed, admin.WithOverrides(func(r *http.Request) bool {
+//		return checkAdminToken(r.Header.Get("Authorization"))
+//	}))
+//
+// Overrides are set by sending a POST request with the form values "set",
+// "param", "value" and optionally "ttl", in the format accepted by
+// time.ParseDuration. Overrides are removed by sending a DELETE request with
+// the "set" and "param" query parameters.
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/simplesurance/proteus"
+)
+
+// Handler creates an http.Handler that serves the current effective
+// configuration, from where each value comes, the status of the providers,
+// the parameters that require a restart to take effect and the recent
+// history of updates.
+func Handler(parsed *proteus.Parsed, opts ...Option) http.Handler {
+	ret := &handler{parsed: parsed}
+	for _, o := range opts {
+		o(ret)
+	}
+
+	return ret
+}
+
+// Option specifies options for the handler.
+type Option func(*handler)
+
+// WithOverrides allows overriding parameter values with the handler. Only
+// requests for which authorize returns true are allowed to do it.
+func WithOverrides(authorize func(*http.Request) bool) Option {
+	return func(h *handler) {
+		h.authorizeOverrideFn = authorize
+	}
+}
+
+type handler struct {
+	parsed              *proteus.Parsed
+	authorizeOverrideFn func(*http.Request) bool
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+	case http.MethodPost, http.MethodDelete:
+		if h.authorizeOverrideFn != nil {
+			h.serveOverride(w, r)
+			return
+		}
+
+		fallthrough
+	default:
+		allow := "GET, HEAD"
+		if h.authorizeOverrideFn != nil {
+			allow += ", POST, DELETE"
+		}
+
+		w.Header().Set("Allow", allow)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	state := h.readState()
+
+	if wantsText(r) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		writeText(w, state)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(state)
+}
+
+func (h *handler) serveOverride(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizeOverrideFn(r) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	setName := r.Form.Get("set")
+	paramName := r.Form.Get("param")
+	if paramName == "" {
+		http.Error(w, `"param" is required`, http.StatusBadRequest)
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		if err := h.parsed.ClearOverride(setName, paramName); err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	value, ok := r.Form["value"]
+	if !ok || len(value) != 1 {
+		http.Error(w, `exactly one "value" is required`, http.StatusBadRequest)
+		return
+	}
+
+	var ttl time.Duration
+	if ttlStr := r.Form.Get("ttl"); ttlStr != "" {
+		var err error
+		ttl, err = time.ParseDuration(ttlStr)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid ttl: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := h.parsed.Override(setName, paramName, value[0], ttl); err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func wantsText(r *http.Request) bool {
+	switch r.URL.Query().Get("format") {
+	case "text":
+		return true
+	case "json":
+		return false
+	}
+
+	return strings.HasPrefix(r.Header.Get("Accept"), "text/plain")
+}
+
+func (h *handler) readState() configState {
+	ret := configState{
+		Params:         []paramState{},
+		PendingRestart: []string{},
+		Providers:      []providerState{},
+		History:        []historyEntry{},
+	}
+
+	for _, param := range h.parsed.State() {
+		state := paramState{
+			SetName:        param.SetName,
+			ParamName:      param.ParamName,
+			Value:          param.Value,
+			IsDefault:      param.IsDefault,
+			Provider:       param.Provider,
+			Secret:         param.Secret,
+			Dynamic:        param.Dynamic,
+			PendingRestart: param.PendingRestart,
+		}
+
+		if param.OverrideExpireError != nil {
+			state.OverrideExpireError = param.OverrideExpireError.Error()
+		}
+
+		ret.Params = append(ret.Params, state)
+
+		if param.PendingRestart {
+			ret.PendingRestart = append(ret.PendingRestart,
+				paramID(param.SetName, param.ParamName))
+		}
+	}
+
+	for _, provider := range h.parsed.Providers() {
+		state := providerState{
+			Name:            provider.Name,
+			Priority:        provider.Priority,
+			Healthy:         provider.Healthy(),
+			LastUpdate:      optionalTime(provider.LastUpdate),
+			RejectedUpdates: provider.RejectedUpdates,
+			UnknownParams:   provider.UnknownParams,
+		}
+
+		if provider.FromLastKnownGood {
+			state.LastKnownGood = optionalTime(provider.LastKnownGoodTime)
+		}
+
+		if provider.LastError != nil {
+			state.LastError = provider.LastError.Error()
+			state.LastErrorTime = optionalTime(provider.LastErrorTime)
+		}
+
+		ret.Providers = append(ret.Providers, state)
+	}
+
+	for _, entry := range h.parsed.History() {
+		hEntry := historyEntry{
+			Time:     entry.Time,
+			Provider: entry.Provider,
+			Applied:  entry.Applied,
+			Changes:  []paramChange{},
+		}
+
+		for _, change := range entry.Changes {
+			hEntry.Changes = append(hEntry.Changes, paramChange{
+				SetName:   change.SetName,
+				ParamName: change.ParamName,
+				OldValue:  change.OldValue,
+				NewValue:  change.NewValue,
+			})
+		}
+
+		for _, violation := range entry.Violations {
+			hEntry.Violations = append(hEntry.Violations, violation.String())
+		}
+
+		ret.History = append(ret.History, hEntry)
+	}
+
+	return ret
+}
+
+func writeText(w io.Writer, state configState) {
+	fmt.Fprintln(w, "PARAMETERS")
+	for _, param := range state.Params {
+		var details []string
+		if param.IsDefault {
+			details = append(details, "default")
+		} else {
+			details = append(details, "from "+param.Provider)
+		}
+
+		if param.PendingRestart {
+			details = append(details, "pending restart")
+		}
+
+		if param.OverrideExpireError != "" {
+			details = append(details, "expired override not removed: "+param.OverrideExpireError)
+		}
+
+		fmt.Fprintf(w, "- %s = %q (%s)\n",
+			paramID(param.SetName, param.ParamName),
+			param.Value,
+			strings.Join(details, "; "))
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "PROVIDERS")
+	for _, provider := range state.Providers {
+		status := "healthy"
+		if !provider.Healthy {
+			status = "error: " + provider.LastError
+		}
+
+		lastUpdate := "never"
+		if provider.LastUpdate != nil {
+			lastUpdate = provider.LastUpdate.Format(time.RFC3339)
+		}
+
+		if provider.LastKnownGood != nil {
+			status += "; using last known good values from " +
+				provider.LastKnownGood.Format(time.RFC3339)
+		}
+
+		if len(provider.UnknownParams) > 0 {
+			status += "; ignored unknown parameters: " +
+				strings.Join(provider.UnknownParams, ", ")
+		}
+
+		fmt.Fprintf(w, "- %d %s: %s (last update: %s, rejected updates: %d)\n",
+			provider.Priority, provider.Name, status,
+			lastUpdate, provider.RejectedUpdates)
+	}
+
+	if len(state.History) == 0 {
+		return
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "HISTORY")
+	for _, entry := range state.History {
+		result := "applied"
+		if !entry.Applied {
+			result = "rejected"
+		}
+
+		fmt.Fprintf(w, "- %s %s: %s\n",
+			entry.Time.Format(time.RFC3339), entry.Provider, result)
+
+		for _, change := range entry.Changes {
+			fmt.Fprintf(w, "  %s: %q => %q\n",
+				paramID(change.SetName, change.ParamName),
+				change.OldValue, change.NewValue)
+		}
+
+		for _, violation := range entry.Violations {
+			fmt.Fprintf(w, "  %s\n", violation)
+		}
+	}
+}
+
+func paramID(setName, paramName string) string {
+	if setName == "" {
+		return paramName
+	}
+
+	return setName + "." + paramName
+}
+
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+
+	return &t
+}
@@ -0,0 +1,210 @@
+//go:build unittest || !integrationtest
+// +build unittest !integrationtest
+
+package admin_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/simplesurance/proteus"
+	"github.com/simplesurance/proteus/admin"
+	"github.com/simplesurance/proteus/internal/assert"
+	"github.com/simplesurance/proteus/plog"
+	"github.com/simplesurance/proteus/sources/cfgtest"
+	"github.com/simplesurance/proteus/types"
+	"github.com/simplesurance/proteus/xtypes"
+)
+
+func TestHandler(t *testing.T) {
+	params := struct {
+		Port  uint16 `param:",optional"`
+		Level *xtypes.Integer[int]
+		DB    struct {
+			Pwd string `param:",secret"`
+		}
+	}{
+		Port: 8080,
+	}
+
+	provider := cfgtest.New(types.ParamValues{
+		"":   {"level": "1"},
+		"db": {"pwd": "my-password"},
+	})
+
+	parsed, err := proteus.MustParse(&params,
+		proteus.WithLogger(plog.TestLogger(t)),
+		proteus.WithHistory(10),
+		proteus.WithProviders(provider))
+	assert.NoErrorNow(t, err)
+	defer stop(t, parsed)
+
+	provider.Update("", "level", ptr("2"))
+	provider.Update("", "port", ptr("9090"))
+
+	server := httptest.NewServer(admin.Handler(parsed))
+	defer server.Close()
+
+	t.Run("json", func(t *testing.T) {
+		body := get(t, server.URL, "")
+
+		var doc struct {
+			Params []struct {
+				SetName        string `json:"set"`
+				ParamName      string `json:"param"`
+				Value          string `json:"value"`
+				IsDefault      bool   `json:"is_default"`
+				Provider       string `json:"provider"`
+				PendingRestart bool   `json:"pending_restart"`
+			} `json:"params"`
+			PendingRestart []string `json:"pending_restart"`
+			Providers      []struct {
+				Name    string `json:"name"`
+				Healthy bool   `json:"healthy"`
+			} `json:"providers"`
+			History []struct {
+				Applied bool `json:"applied"`
+			} `json:"history"`
+		}
+		assert.NoErrorNow(t, json.Unmarshal([]byte(body), &doc))
+
+		values := map[string]string{}
+		for _, p := range doc.Params {
+			values[p.SetName+"."+p.ParamName] = p.Value
+			if p.ParamName == "level" {
+				assert.Equal(t, "*cfgtest.TestProvider", p.Provider)
+			}
+		}
+
+		assert.Equal(t, "<redacted>", values["db.pwd"])
+		assert.Equal(t, "2", values[".level"])
+		assert.Equal(t, "9090", values[".port"])
+
+		assert.EqualNow(t, 1, len(doc.PendingRestart))
+		assert.Equal(t, "port", doc.PendingRestart[0])
+
+		assert.EqualNow(t, 1, len(doc.Providers))
+		assert.Equal(t, true, doc.Providers[0].Healthy)
+
+		assert.Equal(t, 2, len(doc.History))
+		assert.True(t, !strings.Contains(body, "my-password"), "secret must not be leaked")
+	})
+
+	t.Run("text", func(t *testing.T) {
+		body := get(t, server.URL+"?format=text", "")
+		t.Log(body)
+
+		assert.StringContains(t, body, `- db.pwd = "<redacted>" (from *cfgtest.TestProvider)`)
+		assert.StringContains(t, body, `- port = "9090" (from *cfgtest.TestProvider; pending restart)`)
+		assert.StringContains(t, body, `level: "1" => "2"`)
+		assert.True(t, !strings.Contains(body, "my-password"), "secret must not be leaked")
+
+		assert.Equal(t, body, get(t, server.URL, "text/plain"))
+	})
+
+	t.Run("method not allowed", func(t *testing.T) {
+		req, err := http.NewRequestWithContext(context.Background(),
+			http.MethodPost, server.URL, nil)
+		assert.NoErrorNow(t, err)
+
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoErrorNow(t, err)
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	})
+}
+
+func get(t *testing.T, url, accept string) string {
+	t.Helper()
+
+	req, err := http.NewRequestWithContext(context.Background(),
+		http.MethodGet, url, nil)
+	assert.NoErrorNow(t, err)
+
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoErrorNow(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	body, err := io.ReadAll(resp.Body)
+	assert.NoErrorNow(t, err)
+
+	return string(body)
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestHandlerOverrides(t *testing.T) {
+	params := struct {
+		Level *xtypes.Integer[int]
+	}{}
+
+	provider := cfgtest.New(types.ParamValues{
+		"": {"level": "1"},
+	})
+
+	parsed, err := proteus.MustParse(&params,
+		proteus.WithProviders(provider))
+	assert.NoErrorNow(t, err)
+	defer stop(t, parsed)
+
+	const token = "admin-token"
+	server := httptest.NewServer(admin.Handler(parsed,
+		admin.WithOverrides(func(r *http.Request) bool {
+			return r.Header.Get("Authorization") == token
+		})))
+	defer server.Close()
+
+	send := func(method, auth string, form url.Values) int {
+		req, err := http.NewRequestWithContext(context.Background(),
+			method, server.URL+"?"+form.Encode(), nil)
+		assert.NoErrorNow(t, err)
+		req.Header.Set("Authorization", auth)
+
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoErrorNow(t, err)
+		defer resp.Body.Close()
+
+		return resp.StatusCode
+	}
+
+	override := url.Values{"param": {"level"}, "value": {"5"}, "ttl": {"1h"}}
+
+	assert.Equal(t, http.StatusForbidden, send(http.MethodPost, "wrong", override))
+	assert.Equal(t, 1, params.Level.Value())
+
+	assert.Equal(t, http.StatusNoContent, send(http.MethodPost, token, override))
+	assert.Equal(t, 5, params.Level.Value())
+
+	invalid := url.Values{"param": {"level"}, "value": {"x"}}
+	assert.Equal(t, http.StatusUnprocessableEntity, send(http.MethodPost, token, invalid))
+	assert.Equal(t, 5, params.Level.Value())
+
+	clearOverride := url.Values{"param": {"level"}}
+	assert.Equal(t, http.StatusNoContent, send(http.MethodDelete, token, clearOverride))
+	assert.Equal(t, 1, params.Level.Value())
+}
+
+func stop(t *testing.T, parsed *proteus.Parsed) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	assert.NoError(t, parsed.Stop(ctx))
+}
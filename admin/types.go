@@ -0,0 +1,53 @@
+package admin
+
+import "time"
+
+// configState is the document served by the handler.
+type configState struct {
+	Params         []paramState    `json:"params"`
+	PendingRestart []string        `json:"pending_restart"`
+	Providers      []providerState `json:"providers"`
+	History        []historyEntry  `json:"history"`
+}
+
+type paramState struct {
+	SetName             string `json:"set,omitempty"`
+	ParamName           string `json:"param"`
+	Value               string `json:"value"`
+	IsDefault           bool   `json:"is_default"`
+	Provider            string `json:"provider,omitempty"`
+	Secret              bool   `json:"secret"`
+	Dynamic             bool   `json:"dynamic"`
+	PendingRestart      bool   `json:"pending_restart"`
+	OverrideExpireError string `json:"override_expire_error,omitempty"`
+}
+
+type providerState struct {
+	Name            string     `json:"name"`
+	Priority        int        `json:"priority"`
+	Healthy         bool       `json:"healthy"`
+	LastUpdate      *time.Time `json:"last_update,omitempty"`
+	LastError       string     `json:"last_error,omitempty"`
+	LastErrorTime   *time.Time `json:"last_error_time,omitempty"`
+	RejectedUpdates int        `json:"rejected_updates"`
+	UnknownParams   []string   `json:"unknown_params,omitempty"`
+
+	// LastKnownGood is set when the values being used for the provider
+	// come from the last-known-good cache; it is when they were stored
+	LastKnownGood *time.Time `json:"last_known_good,omitempty"`
+}
+
+type historyEntry struct {
+	Time       time.Time     `json:"time"`
+	Provider   string        `json:"provider"`
+	Applied    bool          `json:"applied"`
+	Changes    []paramChange `json:"changes"`
+	Violations []string      `json:"violations,omitempty"`
+}
+
+type paramChange struct {
+	SetName   string `json:"set,omitempty"`
+	ParamName string `json:"param"`
+	OldValue  string `json:"old_value"`
+	NewValue  string `json:"new_value"`
+}
@@ -0,0 +1,138 @@
+package proteus
+
+import (
+	"sync"
+	"time"
+
+	"github.com/simplesurance/proteus/types"
+)
+
+// DebounceOptions specifies how updates from a provider are debounced and
+// coalesced. Providers always send all their values on each update, so when
+// many updates arrive in a short time only the most recent one needs to be
+// applied.
+type DebounceOptions struct {
+	// MinInterval is how long proteus waits without receiving new updates
+	// from the provider before applying the most recent one. Debouncing is
+	// disabled when it is not positive.
+	MinInterval time.Duration
+
+	// MaxLatency limits how long an update can be delayed when the
+	// provider keeps sending updates more often than MinInterval. If not
+	// positive, there is no limit.
+	MaxLatency time.Duration
+}
+
+func (o DebounceOptions) enabled() bool {
+	return o.MinInterval > 0
+}
+
+// debouncer delays updates from a provider until they settle, applying
+// only the most recent values.
+type debouncer struct {
+	opts    DebounceOptions
+	applyFn func(types.ParamValues) error
+
+	// applyMutex makes sure that values are applied in the order they
+	// are received
+	applyMutex sync.Mutex
+
+	mutex   sync.Mutex
+	pending types.ParamValues
+	waiters []chan error // receive the result of applying pending
+	first   time.Time    // when the oldest pending update was received
+	last    time.Time    // when the newest pending update was received
+	timer   *time.Timer  // set while there are pending values
+	stopped bool
+}
+
+func newDebouncer(opts DebounceOptions, applyFn func(types.ParamValues) error) *debouncer {
+	return &debouncer{
+		opts:    opts,
+		applyFn: applyFn,
+	}
+}
+
+// submit schedules v to be applied, replacing any values not applied yet.
+// The returned channel receives the result of applying the values, which
+// is shared by all updates coalesced with v.
+func (d *debouncer) submit(v types.ParamValues) <-chan error {
+	ret := make(chan error, 1)
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.stopped {
+		ret <- errStopped
+		return ret
+	}
+
+	now := time.Now()
+	d.pending = v
+	d.last = now
+	d.waiters = append(d.waiters, ret)
+
+	if d.timer == nil {
+		d.first = now
+		d.timer = time.AfterFunc(d.remaining(now), d.flush)
+	}
+
+	return ret
+}
+
+// flush applies the pending values, unless new values were received too
+// recently, in which case it reschedules itself.
+func (d *debouncer) flush() {
+	d.applyMutex.Lock()
+	defer d.applyMutex.Unlock()
+
+	d.mutex.Lock()
+	if d.stopped || len(d.waiters) == 0 {
+		d.mutex.Unlock()
+		return
+	}
+
+	if wait := d.remaining(time.Now()); wait > 0 {
+		d.timer = time.AfterFunc(wait, d.flush)
+		d.mutex.Unlock()
+		return
+	}
+
+	values, waiters := d.pending, d.waiters
+	d.pending, d.waiters, d.timer = nil, nil, nil
+	d.mutex.Unlock()
+
+	err := d.applyFn(values)
+	for _, w := range waiters {
+		w <- err
+	}
+}
+
+// remaining returns how long to wait before applying the pending values.
+// Caller must hold the mutex.
+func (d *debouncer) remaining(now time.Time) time.Duration {
+	wait := d.last.Add(d.opts.MinInterval).Sub(now)
+	if d.opts.MaxLatency > 0 {
+		wait = min(wait, d.first.Add(d.opts.MaxLatency).Sub(now))
+	}
+
+	return wait
+}
+
+// stop discards pending values; updates waiting for them are notified that
+// they were not applied.
+func (d *debouncer) stop() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.stopped = true
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+
+	for _, w := range d.waiters {
+		w <- errStopped
+	}
+
+	d.pending, d.waiters, d.timer = nil, nil, nil
+}
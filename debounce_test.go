@@ -0,0 +1,154 @@
+//go:build unittest || !integrationtest
+// +build unittest !integrationtest
+
+package proteus_test
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/simplesurance/proteus"
+	"github.com/simplesurance/proteus/internal/assert"
+	"github.com/simplesurance/proteus/sources/cfgtest"
+	"github.com/simplesurance/proteus/types"
+	"github.com/simplesurance/proteus/xtypes"
+)
+
+func TestDebounce(t *testing.T) {
+	updates := &recordedUpdates{}
+	params := struct {
+		Level *xtypes.Integer[int]
+	}{
+		Level: &xtypes.Integer[int]{UpdateFn: updates.add},
+	}
+
+	provider := cfgtest.New(types.ParamValues{"": {"level": "0"}})
+
+	parsed, err := proteus.MustParse(&params,
+		proteus.WithHistory(10),
+		proteus.WithDebounce(proteus.DebounceOptions{MinInterval: 100 * time.Millisecond}),
+		proteus.WithProviders(provider))
+	assert.NoErrorNow(t, err)
+	defer stop(t, parsed)
+
+	// intermediate states, even invalid ones, must not be applied
+	for i := 1; i <= 10; i++ {
+		provider.Update("", "level", ptr(strconv.Itoa(i)))
+		provider.Update("", "level", ptr("invalid"))
+	}
+	provider.Update("", "level", ptr("42"))
+
+	updates.waitFor(t, 2)
+	time.Sleep(200 * time.Millisecond)
+
+	// the first call is done when the configuration is parsed
+	got := updates.get()
+	assert.EqualNow(t, 2, len(got))
+	assert.Equal(t, 0, got[0])
+	assert.Equal(t, 42, got[1])
+	assert.Equal(t, 42, params.Level.Value())
+
+	history := parsed.History()
+	assert.EqualNow(t, 1, len(history))
+	assert.True(t, history[0].Applied, "coalesced update must be applied")
+}
+
+func TestDebounceMaxLatency(t *testing.T) {
+	updates := &recordedUpdates{}
+	params := struct {
+		Level *xtypes.Integer[int]
+	}{
+		Level: &xtypes.Integer[int]{UpdateFn: updates.add},
+	}
+
+	provider := cfgtest.New(types.ParamValues{"": {"level": "0"}})
+
+	parsed, err := proteus.MustParse(&params,
+		proteus.WithDebounce(proteus.DebounceOptions{
+			MinInterval: time.Second,
+			MaxLatency:  100 * time.Millisecond,
+		}),
+		proteus.WithProviders(provider))
+	assert.NoErrorNow(t, err)
+	defer stop(t, parsed)
+
+	// the provider never settles, but updates must still be applied
+	for i := 1; i <= 50; i++ {
+		provider.Update("", "level", ptr(strconv.Itoa(i)))
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	assert.True(t, len(updates.get()) > 1, "updates must be applied after the max latency")
+}
+
+func TestProviderDebounceWithResult(t *testing.T) {
+	params := struct {
+		Level *xtypes.Integer[int]
+	}{}
+
+	debounced := cfgtest.New(types.ParamValues{})
+	immediate := cfgtest.New(types.ParamValues{"": {"level": "0"}})
+
+	parsed, err := proteus.MustParse(&params,
+		proteus.WithProviderDebounce(debounced, proteus.DebounceOptions{
+			MinInterval: 50 * time.Millisecond,
+		}),
+		proteus.WithProviders(debounced, immediate))
+	assert.NoErrorNow(t, err)
+	defer stop(t, parsed)
+
+	// concurrent updates are coalesced, and share the result of the
+	// update that is applied
+	var wg sync.WaitGroup
+	errs := make([]error, 5)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = debounced.UpdateWithResult("", "level", ptr("invalid"))
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		assert.Error(t, err)
+	}
+
+	assert.NoErrorNow(t, debounced.UpdateWithResult("", "level", ptr("7")))
+	assert.Equal(t, 7, params.Level.Value())
+
+	// the other provider is not debounced
+	start := time.Now()
+	assert.NoErrorNow(t, immediate.UpdateWithResult("", "level", ptr("1")))
+	assert.True(t, time.Since(start) < 50*time.Millisecond, "update must not be delayed")
+}
+
+// recordedUpdates records the values received by an UpdateFn callback.
+type recordedUpdates struct {
+	mutex  sync.Mutex
+	values []int
+}
+
+func (r *recordedUpdates) add(v int) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.values = append(r.values, v)
+}
+
+func (r *recordedUpdates) get() []int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return append([]int(nil), r.values...)
+}
+
+func (r *recordedUpdates) waitFor(t *testing.T, count int) {
+	t.Helper()
+
+	assert.Eventually(t, 2*time.Second, func() bool { return len(r.get()) >= count },
+		fmt.Sprintf("waiting for %d updates", count))
+}
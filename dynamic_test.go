@@ -61,7 +61,7 @@ func TestDynamic(t *testing.T) {
 		t.Error(buffer.String())
 	}
 
-	defer parsed.Stop()
+	defer stop(t, parsed)
 
 	assert.EqualNow(t, wantedValues[0], params.X.Value())
 	assert.EqualNow(t, wantedValues[0], params.Y)
@@ -0,0 +1,157 @@
+package proteus
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/simplesurance/proteus/types"
+)
+
+// HistoryEntry describes one update of parameter values received from a
+// provider after the application started. See WithHistory.
+type HistoryEntry struct {
+	// Time is when the update was received.
+	Time time.Time
+
+	// Provider is the name of the provider that sent the update.
+	Provider string
+
+	// Changes are the parameters that were changed by the update. Values
+	// are redacted.
+	Changes []ParamChange
+
+	// Applied is true if the update was accepted.
+	Applied bool
+
+	// Violations describes why the update was rejected.
+	Violations types.ErrViolations
+}
+
+// ParamChange describes the change in the value of a parameter, as provided
+// by a single provider. Values are redacted.
+type ParamChange struct {
+	SetName   string
+	ParamName string
+	OldValue  string
+	NewValue  string
+}
+
+// History returns the most recent updates received from providers, from the
+// oldest to the newest. The number of entries kept is defined by the
+// WithHistory option; if the option is not provided, nothing is kept.
+func (p *Parsed) History() []HistoryEntry {
+	p.history.mutex.Lock()
+	defer p.history.mutex.Unlock()
+
+	ret := make([]HistoryEntry, 0, len(p.history.entries))
+	ret = append(ret, p.history.entries[p.history.next:]...)
+	ret = append(ret, p.history.entries[:p.history.next]...)
+
+	return ret
+}
+
+// historyBuffer is a ring buffer of history entries.
+type historyBuffer struct {
+	mutex   sync.Mutex
+	entries []HistoryEntry
+	next    int // position of the next write when the buffer is full
+}
+
+// recordHistory stores information about an update received from a
+// provider. Updates that did not change anything are only stored if they
+// were rejected.
+func (p *Parsed) recordHistory(
+	providerName string,
+	previous, current types.ParamValues,
+	err error,
+) {
+	size := p.settings.historySize
+	if size <= 0 {
+		return
+	}
+
+	entry := HistoryEntry{
+		Time:     time.Now(),
+		Provider: providerName,
+		Changes:  p.diffValues(previous, current),
+		Applied:  err == nil,
+	}
+
+	if err != nil {
+		if !errors.As(err, &entry.Violations) {
+			entry.Violations = types.ErrViolations{{Message: err.Error()}}
+		}
+	} else if len(entry.Changes) == 0 {
+		return
+	}
+
+	p.history.mutex.Lock()
+	defer p.history.mutex.Unlock()
+
+	if len(p.history.entries) < size {
+		p.history.entries = append(p.history.entries, entry)
+		return
+	}
+
+	p.history.entries[p.history.next] = entry
+	p.history.next = (p.history.next + 1) % size
+}
+
+// diffValues returns the redacted changes between two sets of values from
+// the same provider.
+func (p *Parsed) diffValues(previous, current types.ParamValues) []ParamChange {
+	var ret []ParamChange
+
+	setNames := map[string]struct{}{}
+	for setName := range previous {
+		setNames[setName] = struct{}{}
+	}
+	for setName := range current {
+		setNames[setName] = struct{}{}
+	}
+
+	for _, setName := range mapKeysSorted(setNames) {
+		paramNames := map[string]struct{}{}
+		for paramName := range previous[setName] {
+			paramNames[paramName] = struct{}{}
+		}
+		for paramName := range current[setName] {
+			paramNames[paramName] = struct{}{}
+		}
+
+		for _, paramName := range mapKeysSorted(paramNames) {
+			oldValue := previous.Get(setName, paramName)
+			newValue := current.Get(setName, paramName)
+
+			if oldValue == nil && newValue == nil {
+				continue
+			}
+
+			if oldValue != nil && newValue != nil && *oldValue == *newValue {
+				continue
+			}
+
+			ret = append(ret, ParamChange{
+				SetName:   setName,
+				ParamName: paramName,
+				OldValue:  p.redact(setName, paramName, oldValue),
+				NewValue:  p.redact(setName, paramName, newValue),
+			})
+		}
+	}
+
+	return ret
+}
+
+// redact returns the redacted representation of a value of a parameter.
+// Values of parameters unknown to the application are fully redacted,
+// since it is not known if they are secret.
+func (p *Parsed) redact(setName, paramName string, value *string) string {
+	param, ok := p.inferedConfig.getParam(setName, paramName)
+	if !ok {
+		return redactedPlaceholder
+	}
+
+	return param.redactedValue(value)()
+}
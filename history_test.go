@@ -0,0 +1,60 @@
+//go:build unittest || !integrationtest
+// +build unittest !integrationtest
+
+package proteus_test
+
+import (
+	"testing"
+
+	"github.com/simplesurance/proteus"
+	"github.com/simplesurance/proteus/internal/assert"
+	"github.com/simplesurance/proteus/sources/cfgtest"
+	"github.com/simplesurance/proteus/types"
+	"github.com/simplesurance/proteus/xtypes"
+)
+
+func TestHistory(t *testing.T) {
+	params := struct {
+		Level *xtypes.Integer[int]
+		Token *xtypes.String `param:",secret"`
+	}{}
+
+	provider := cfgtest.New(types.ParamValues{
+		"": {"level": "1", "token": "secret1"},
+	})
+
+	parsed, err := proteus.MustParse(&params,
+		proteus.WithHistory(2),
+		proteus.WithProviders(provider))
+	assert.NoErrorNow(t, err)
+	defer stop(t, parsed)
+
+	assert.Equal(t, 0, len(parsed.History()))
+
+	assert.NoErrorNow(t, provider.UpdateWithResult("", "level", ptr("2")))
+	assert.NoErrorNow(t, provider.UpdateWithResult("", "token", ptr("secret2")))
+	assert.ErrorNow(t, provider.UpdateWithResult("", "level", ptr("invalid")))
+
+	// only the 2 most recent entries are kept
+	history := parsed.History()
+	assert.EqualNow(t, 2, len(history))
+
+	assert.Equal(t, true, history[0].Applied)
+	assert.EqualNow(t, 1, len(history[0].Changes))
+	assert.Equal(t, proteus.ParamChange{
+		ParamName: "token",
+		OldValue:  "<redacted>",
+		NewValue:  "<redacted>",
+	}, history[0].Changes[0])
+
+	assert.Equal(t, false, history[1].Applied)
+	assert.Equal(t, "*cfgtest.TestProvider", history[1].Provider)
+	assert.EqualNow(t, 1, len(history[1].Violations))
+	assert.Equal(t, "level", history[1].Violations[0].ParamName)
+	assert.EqualNow(t, 1, len(history[1].Changes))
+	assert.Equal(t, proteus.ParamChange{
+		ParamName: "level",
+		OldValue:  "2",
+		NewValue:  "invalid",
+	}, history[1].Changes[0])
+}
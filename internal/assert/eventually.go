@@ -0,0 +1,21 @@
+package assert
+
+import (
+	"testing"
+	"time"
+)
+
+// Eventually asserts that cond becomes true before the timeout, checking it
+// periodically, and terminates the test immediately if it doesn't.
+func Eventually(t testing.TB, timeout time.Duration, cond func() bool, msg string) {
+	t.Helper()
+
+	start := time.Now()
+	for !cond() {
+		if time.Since(start) > timeout {
+			t.Fatalf("Timeout waiting for condition: %s", msg)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
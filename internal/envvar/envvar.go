@@ -0,0 +1,60 @@
+// Package envvar implements the rules used to map parameters to environment
+// variables, shared by the providers that read them.
+package envvar
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/simplesurance/proteus/internal/paramvalues"
+	"github.com/simplesurance/proteus/sources"
+	"github.com/simplesurance/proteus/types"
+)
+
+// Name produces the name of the environment variable that should be used to
+// configure a parameter. This function is not reversible.
+func Name(setName, valueName, prefix string) string {
+	var ret string
+	if setName == "" {
+		ret = prefix + valueName
+	} else {
+		ret = prefix + setName + "__" + valueName
+	}
+
+	return strings.ToUpper(strings.ReplaceAll(ret, "-", "_"))
+}
+
+// Match maps the variables to the parameters they configure. Variables with
+// the prefix that do not match any parameter are returned as unknown, sorted
+// by name; variables without the prefix are ignored.
+func Match(
+	prefix string,
+	vars map[string]string,
+	paramIDs sources.Parameters,
+) (values types.ParamValues, unknown []string) {
+	values = types.ParamValues{}
+	matched := map[string]bool{}
+	for setName, set := range paramIDs {
+		for paramName := range set {
+			envName := Name(setName, paramName, prefix)
+			value, ok := vars[envName]
+			if !ok {
+				continue
+			}
+
+			paramvalues.Set(values, setName, paramName, value)
+			matched[envName] = true
+		}
+	}
+
+	// names are normalized, the prefix must also be
+	normalizedPrefix := Name("", "", prefix)
+	for envName := range vars {
+		if strings.HasPrefix(envName, normalizedPrefix) && !matched[envName] {
+			unknown = append(unknown, envName)
+		}
+	}
+
+	sort.Strings(unknown)
+	return values, unknown
+}
@@ -0,0 +1,202 @@
+// Package filepoll detects changes on files by polling them. Polling is used
+// instead of OS notifications because it works the same way on all
+// platforms and file systems, including network file systems and volumes
+// mounted on containers.
+package filepoll
+
+import (
+	"context"
+	"crypto/sha256"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Watcher polls a file for changes. A change is detected when the
+// modification time or the size of the file change and the hash of its
+// content is different from the last time it was read.
+type Watcher struct {
+	path     string
+	interval time.Duration
+
+	mutex   sync.Mutex
+	modTime time.Time
+	size    int64
+	hash    [sha256.Size]byte
+
+	started  atomic.Bool
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// New creates a watcher for the file at path, that polls it on the
+// provided interval.
+func New(path string, interval time.Duration) *Watcher {
+	return &Watcher{
+		path:     path,
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Content is the content of a file, and the state of the file when it was
+// read.
+type Content struct {
+	Data []byte
+
+	modTime time.Time
+	size    int64
+}
+
+// ReadFile reads the file at path. The returned content can be used as the
+// starting point of a watcher with SetContent, so changes after the file
+// was read are not missed.
+func ReadFile(path string) (Content, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return Content{}, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Content{}, err
+	}
+
+	return Content{Data: data, modTime: info.ModTime(), size: info.Size()}, nil
+}
+
+// Read reads the file. Only changes after the file was read are reported
+// by the watcher.
+func (w *Watcher) Read() ([]byte, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	data, _, err := w.read(true)
+	return data, err
+}
+
+// SetContent records content, read with ReadFile, as the last content of
+// the file, instead of reading it with Read. Only changes after content
+// was read are reported by the watcher.
+func (w *Watcher) SetContent(content Content) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.record(content)
+}
+
+// Start polls the file on the background. onChange is called with the new
+// content of the file when it changes; onError is called when the file
+// can't be read. Start must be called at most once.
+func (w *Watcher) Start(onChange func([]byte), onError func(error)) {
+	w.started.Store(true)
+
+	go func() {
+		defer close(w.done)
+
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-w.stop:
+				return
+			case <-ticker.C:
+			}
+
+			data, changed, err := w.poll()
+			switch {
+			case err != nil:
+				onError(err)
+			case changed:
+				onChange(data)
+			}
+		}
+	}()
+}
+
+// Stop stops polling the file, and waits until the polling goroutine
+// terminates, or until the context is done. It is safe to call Stop even
+// if Start was not called.
+func (w *Watcher) Stop(ctx context.Context) error {
+	w.stopOnce.Do(func() {
+		close(w.stop)
+	})
+
+	if !w.started.Load() {
+		return nil
+	}
+
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Stopped returns true if Stop was called and the polling goroutine
+// terminated.
+func (w *Watcher) Stopped() bool {
+	select {
+	case <-w.stop:
+	default:
+		return false
+	}
+
+	if !w.started.Load() {
+		return true
+	}
+
+	select {
+	case <-w.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// poll reads the file if its modification time or size changed, returning
+// its content and whether the content changed.
+func (w *Watcher) poll() ([]byte, bool, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if info.ModTime().Equal(w.modTime) && info.Size() == w.size {
+		return nil, false, nil
+	}
+
+	return w.read(false)
+}
+
+// read reads the file and records its state. Caller must hold the mutex.
+func (w *Watcher) read(force bool) ([]byte, bool, error) {
+	content, err := ReadFile(w.path)
+	if err != nil {
+		return nil, false, err
+	}
+
+	changed := w.record(content) || force
+	return content.Data, changed, nil
+}
+
+// record records the state of the file, returning whether its content
+// changed. Caller must hold the mutex.
+func (w *Watcher) record(content Content) bool {
+	hash := sha256.Sum256(content.Data)
+	changed := hash != w.hash
+
+	w.modTime = content.modTime
+	w.size = content.size
+	w.hash = hash
+
+	return changed
+}
@@ -0,0 +1,107 @@
+// Package fileprovider implements the parts shared by providers that read
+// parameters from a single file, optionally watching it for changes.
+// Providers embed Provider and only implement parsing the file.
+package fileprovider
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/simplesurance/proteus/internal/filepoll"
+	"github.com/simplesurance/proteus/plog"
+	"github.com/simplesurance/proteus/sources"
+	"github.com/simplesurance/proteus/types"
+)
+
+// ParseFunc parses the content of the file at path into parameter values.
+type ParseFunc func(path string, data []byte, paramIDs sources.Parameters) (types.ParamValues, error)
+
+// Provider reads parameters from a file. It must be embedded on a type
+// declared on the package of the provider, so the source name of the
+// provider is derived from that package.
+type Provider struct {
+	Path string
+
+	// PollInterval is how often the file is checked for changes. If not
+	// positive, the file is not watched for changes.
+	PollInterval time.Duration
+
+	Parse ParseFunc
+
+	paramIDs sources.Parameters
+	watcher  *filepoll.Watcher
+}
+
+var (
+	_ sources.Reloader       = &Provider{}
+	_ sources.ContextStopper = &Provider{}
+)
+
+func (r *Provider) IsCommandLineFlag() bool {
+	return false
+}
+
+func (r *Provider) Stop() {
+	_ = r.StopContext(context.Background())
+}
+
+// StopContext stops watching the file for changes.
+func (r *Provider) StopContext(ctx context.Context) error {
+	if r.watcher == nil {
+		return nil
+	}
+
+	return r.watcher.Stop(ctx)
+}
+
+func (r *Provider) Watch(
+	paramIDs sources.Parameters,
+	updater sources.Updater,
+) (initial types.ParamValues, _ error) {
+	r.paramIDs = paramIDs
+	r.watcher = filepoll.New(r.Path, r.PollInterval)
+
+	initial, err := r.read()
+	if err != nil {
+		return nil, err
+	}
+
+	if r.PollInterval <= 0 {
+		return initial, nil
+	}
+
+	logger := plog.Logger(updater.Log)
+	r.watcher.Start(
+		func(data []byte) {
+			values, err := r.Parse(r.Path, data, r.paramIDs)
+			if err != nil {
+				logger.E(fmt.Sprintf("Ignoring changes on %s: %v", r.Path, err))
+				updater.ReportError(err)
+				return
+			}
+
+			logger.I(fmt.Sprintf("%s changed, updating parameters", r.Path))
+			updater.Update(values)
+		},
+		func(err error) {
+			logger.E(fmt.Sprintf("Watching %s: %v", r.Path, err))
+			updater.ReportError(err)
+		})
+
+	return initial, nil
+}
+
+// Reload reads the file again.
+func (r *Provider) Reload(_ context.Context) (types.ParamValues, error) {
+	return r.read()
+}
+
+func (r *Provider) read() (types.ParamValues, error) {
+	data, err := r.watcher.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	return r.Parse(r.Path, data, r.paramIDs)
+}
@@ -0,0 +1,97 @@
+// Package jsonparams maps JSON documents to parameter values, as done by the
+// providers that read JSON.
+package jsonparams
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/simplesurance/proteus/internal/paramvalues"
+	"github.com/simplesurance/proteus/sources"
+	"github.com/simplesurance/proteus/types"
+)
+
+// Parse reads the parameters from a JSON object, whose keys are parameters
+// or parameter sets. Source identifies where the document comes from, like
+// a file path, and is used on error messages.
+func Parse(
+	source string,
+	data []byte,
+	paramIDs sources.Parameters,
+) (types.ParamValues, error) {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", source, err)
+	}
+
+	ret := types.ParamValues{}
+	var violations types.ErrViolations
+	for key, raw := range doc {
+		// parameters have priority over sets, allowing parameters
+		// on the root set to receive JSON objects
+		if _, isParam := paramIDs[""][key]; isParam {
+			addValue(ret, "", key, raw)
+			continue
+		}
+
+		set, isSet := paramIDs[key]
+		if !isSet || key == "" {
+			violations = append(violations, types.Violation{
+				Message: fmt.Sprintf(
+					"Key %q on %s does not match any expected application parameter or parameter set",
+					key, source),
+			})
+			continue
+		}
+
+		var setDoc map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &setDoc); err != nil {
+			violations = append(violations, types.Violation{
+				SetName: key,
+				Message: fmt.Sprintf(
+					"Key %q on %s is a parameter set, its value must be an object",
+					key, source),
+			})
+			continue
+		}
+
+		for paramName, paramRaw := range setDoc {
+			if _, ok := set[paramName]; !ok {
+				violations = append(violations, types.Violation{
+					Message: fmt.Sprintf(
+						"Key %q of set %q on %s does not match any expected application parameter",
+						paramName, key, source),
+				})
+				continue
+			}
+
+			addValue(ret, key, paramName, paramRaw)
+		}
+	}
+
+	if len(violations) > 0 {
+		return ret, violations
+	}
+
+	return ret, nil
+}
+
+// addValue converts a JSON value to the value of a parameter, and adds it
+// to values.
+func addValue(values types.ParamValues, setName, paramName string, raw json.RawMessage) {
+	raw = bytes.TrimSpace(raw)
+
+	var value string
+	switch {
+	case bytes.Equal(raw, []byte("null")):
+		return
+	case len(raw) > 0 && raw[0] == '"':
+		// already validated by json.Unmarshal
+		_ = json.Unmarshal(raw, &value)
+	default:
+		value = string(raw)
+	}
+
+	paramvalues.Set(values, setName, paramName, value)
+}
@@ -0,0 +1,40 @@
+// Package paramvalues has helpers to manipulate the values of parameters,
+// shared by proteus and the providers.
+package paramvalues
+
+import (
+	"maps"
+
+	"github.com/simplesurance/proteus/types"
+)
+
+// Set sets the value of a parameter, creating its set if needed.
+func Set(values types.ParamValues, setName, paramName, value string) {
+	set, ok := values[setName]
+	if !ok {
+		set = map[string]string{}
+		values[setName] = set
+	}
+
+	set[paramName] = value
+}
+
+// Equal returns true if both have the same values. A nil value is only
+// equal to another nil value.
+func Equal(a, b types.ParamValues) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+
+	return maps.EqualFunc(a, b, maps.Equal)
+}
+
+// EqualValue returns true if both values are nil, or if both are set to the
+// same value.
+func EqualValue(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return *a == *b
+}
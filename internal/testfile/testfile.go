@@ -0,0 +1,29 @@
+// Package testfile has helpers for tests that read files.
+package testfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/simplesurance/proteus/internal/assert"
+)
+
+// Write writes content to the file at path, creating its directory if
+// needed. The modification time of files that already exist is advanced,
+// so changes are detected even on file systems with low resolution
+// timestamps.
+func Write(t testing.TB, path, content string) {
+	t.Helper()
+
+	if info, err := os.Stat(path); err == nil {
+		defer func() {
+			modTime := info.ModTime().Add(time.Second)
+			assert.NoErrorNow(t, os.Chtimes(path, modTime, modTime))
+		}()
+	}
+
+	assert.NoErrorNow(t, os.MkdirAll(filepath.Dir(path), 0o700))
+	assert.NoErrorNow(t, os.WriteFile(path, []byte(content), 0o600))
+}
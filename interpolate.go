@@ -0,0 +1,237 @@
+package proteus
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/simplesurance/proteus/types"
+)
+
+// interpolate returns the values with the references to other parameters
+// and to environment variables expanded. See WithInterpolation. Values that
+// can't be expanded are kept unchanged, and the problems are returned as
+// violations.
+//
+// Caller must hold the mutex.
+func (p *Parsed) interpolate(merged types.ParamValues) (types.ParamValues, types.ErrViolations) {
+	in := newInterpolator(p, merged)
+
+	ret := merged.Copy()
+	var violations types.ErrViolations
+	for setName, set := range ret {
+		for paramName := range set {
+			if _, exists := p.inferedConfig.getParam(setName, paramName); !exists {
+				continue
+			}
+
+			value, err := in.resolve(paramID{setName, paramName})
+			if err != nil {
+				violations = append(violations, types.Violation{
+					SetName:   setName,
+					ParamName: paramName,
+					Message:   err.Error(),
+				})
+				continue
+			}
+
+			set[paramName] = value
+		}
+	}
+
+	return ret, violations
+}
+
+// valuesInterpolator returns an interpolator for the current values, to be
+// used with desiredValue while the mutex is held, or nil if interpolation is
+// disabled. The values are only merged when the first reference is
+// expanded.
+//
+// Caller must hold the mutex.
+func (p *Parsed) valuesInterpolator() *interpolator {
+	if !p.settings.interpolation {
+		return nil
+	}
+
+	return newInterpolator(p, nil)
+}
+
+type paramID struct {
+	setName   string
+	paramName string
+}
+
+func (id paramID) String() string {
+	if id.setName == "" {
+		return id.paramName
+	}
+
+	return id.setName + "." + id.paramName
+}
+
+// interpolator expands references on values, caching the result for each
+// parameter.
+type interpolator struct {
+	parsed   *Parsed
+	merged   types.ParamValues
+	resolved map[paramID]string
+	failed   map[paramID]error
+
+	// stack has the parameters being expanded, to detect cycles
+	stack []paramID
+}
+
+func newInterpolator(p *Parsed, merged types.ParamValues) *interpolator {
+	return &interpolator{
+		parsed:   p,
+		merged:   merged,
+		resolved: map[paramID]string{},
+		failed:   map[paramID]error{},
+	}
+}
+
+// expanded returns value, provided for the parameter, with the references
+// expanded. If in is nil or the value can't be expanded, value is returned
+// unchanged.
+func (in *interpolator) expanded(setName, paramName string, value *string) *string {
+	if in == nil || value == nil || !strings.Contains(*value, "$") {
+		return value
+	}
+
+	if in.merged == nil {
+		in.merged = in.parsed.mergeRawValues()
+	}
+
+	ret, err := in.resolve(paramID{setName, paramName})
+	if err != nil {
+		return value
+	}
+
+	return &ret
+}
+
+// resolve returns the expanded value of the parameter. Parameters without
+// value use the default value, that is not expanded.
+func (in *interpolator) resolve(id paramID) (string, error) {
+	if value, ok := in.resolved[id]; ok {
+		return value, nil
+	}
+
+	if err, ok := in.failed[id]; ok {
+		return "", err
+	}
+
+	for ix, stacked := range in.stack {
+		if stacked == id {
+			cycle := make([]string, 0, len(in.stack)-ix+1)
+			for _, s := range in.stack[ix:] {
+				cycle = append(cycle, s.String())
+			}
+
+			cycle = append(cycle, id.String())
+			return "", fmt.Errorf("references form a cycle: %s", strings.Join(cycle, " -> "))
+		}
+	}
+
+	field, _ := in.parsed.inferedConfig.getParam(id.setName, id.paramName)
+	raw := in.merged.Get(id.setName, id.paramName)
+	if raw == nil {
+		return field.getDefaultFn()
+	}
+
+	in.stack = append(in.stack, id)
+	value, err := in.expand(field, *raw)
+	in.stack = in.stack[:len(in.stack)-1]
+
+	if err != nil {
+		in.failed[id] = err
+		return "", err
+	}
+
+	in.resolved[id] = value
+	return value, nil
+}
+
+// expand replaces the references on a value of the parameter field.
+func (in *interpolator) expand(field paramSetField, value string) (string, error) {
+	var sb strings.Builder
+	for {
+		start := strings.Index(value, "${")
+		if start < 0 {
+			sb.WriteString(value)
+			return sb.String(), nil
+		}
+
+		// "$${" is a literal "${"
+		if start > 0 && value[start-1] == '$' {
+			sb.WriteString(value[:start-1])
+			sb.WriteString("${")
+			value = value[start+2:]
+			continue
+		}
+
+		end := strings.IndexByte(value[start:], '}')
+		if end < 0 {
+			if field.secret {
+				return "", errors.New("value has a reference that is not terminated with \"}\"")
+			}
+
+			return "", fmt.Errorf("reference %q is not terminated with \"}\"", value[start:])
+		}
+
+		ref := value[start+2 : start+end]
+		expanded, err := in.expandReference(field, ref)
+		if err != nil {
+			return "", err
+		}
+
+		sb.WriteString(value[:start])
+		sb.WriteString(expanded)
+		value = value[start+end+1:]
+	}
+}
+
+func (in *interpolator) expandReference(field paramSetField, ref string) (string, error) {
+	// the text of references on secrets may be part of the secret, so it
+	// is never included in errors
+	desc := "${" + ref + "}"
+	if field.secret {
+		desc = "value has a reference that"
+	}
+
+	if name, ok := strings.CutPrefix(ref, "env:"); ok {
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			if field.secret {
+				return "", errors.New("value references an environment variable that is not set")
+			}
+
+			return "", fmt.Errorf("environment variable %q referenced by %s is not set", name, desc)
+		}
+
+		return value, nil
+	}
+
+	id := paramID{paramName: ref}
+	if setName, paramName, ok := strings.Cut(ref, "."); ok {
+		id = paramID{setName, paramName}
+	}
+
+	refField, exists := in.parsed.inferedConfig.getParam(id.setName, id.paramName)
+	if !exists || refField.paramSet {
+		return "", fmt.Errorf("%s does not reference a known parameter", desc)
+	}
+
+	if refField.secret && !field.secret {
+		return "", fmt.Errorf(
+			"%s references a secret, that can't be used on parameters that are not secrets", desc)
+	}
+
+	value, err := in.resolve(id)
+	if err != nil {
+		return "", fmt.Errorf("%s can't be expanded: %w", desc, err)
+	}
+
+	return value, nil
+}
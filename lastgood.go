@@ -0,0 +1,346 @@
+package proteus
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/simplesurance/proteus/internal/paramvalues"
+	"github.com/simplesurance/proteus/sources"
+	"github.com/simplesurance/proteus/types"
+)
+
+// lastKnownGoodProviderSuffix is appended to the name of providers on
+// provenance information when their values come from the last-known-good
+// cache.
+const lastKnownGoodProviderSuffix = " (last known good)"
+
+// LastKnownGoodOptions specifies how the last known good values from
+// providers are cached. See WithLastKnownGood.
+type LastKnownGoodOptions struct {
+	// Dir is the directory where the values are stored. It is created
+	// if it does not exist.
+	Dir string
+
+	// Providers are the providers whose values are cached. If empty,
+	// values from all providers, except the ones that read command-line
+	// flags, are cached.
+	Providers []sources.Provider
+
+	// SecretsKey is a 32 bytes key used to encrypt values of secret
+	// parameters with AES-256-GCM before storing them. If not provided,
+	// values of secret parameters are not stored, and the cache can only
+	// be used if those parameters are provided by other providers or are
+	// optional.
+	SecretsKey []byte
+}
+
+// caches returns true if values from the provider must be cached.
+func (o *LastKnownGoodOptions) caches(provider sources.Provider) bool {
+	if len(o.Providers) == 0 {
+		return !provider.IsCommandLineFlag()
+	}
+
+	for _, p := range o.Providers {
+		if p == provider {
+			return true
+		}
+	}
+
+	return false
+}
+
+// lastKnownGood stores the last known good values of a provider on disk.
+type lastKnownGood struct {
+	path         string
+	providerName string
+	aead         cipher.AEAD // nil if secrets are not stored
+
+	// saved are the values last written to disk.
+	// Guarded by Parsed.lastKnownGoodMutex.
+	saved types.ParamValues
+}
+
+// lastKnownGoodFile is the format of the cache file.
+type lastKnownGoodFile struct {
+	Provider string            `json:"provider"`
+	SavedAt  time.Time         `json:"saved_at"`
+	Values   types.ParamValues `json:"values"`
+
+	// Secrets are the encrypted values of secret parameters, base64
+	// encoded, with the nonce prepended.
+	Secrets types.ParamValues `json:"secrets,omitempty"`
+}
+
+func newLastKnownGood(opts *LastKnownGoodOptions, ix int, providerName string) *lastKnownGood {
+	ret := &lastKnownGood{
+		path: filepath.Join(opts.Dir,
+			fmt.Sprintf("%d-%s.json", ix, safeFileName(providerName))),
+		providerName: providerName,
+	}
+
+	if opts.SecretsKey != nil {
+		block, err := aes.NewCipher(opts.SecretsKey)
+		if err != nil || len(opts.SecretsKey) != 32 {
+			panic(fmt.Errorf("INVALID LAST KNOWN GOOD SECRETS KEY: must have 32 bytes"))
+		}
+
+		ret.aead, err = cipher.NewGCM(block)
+		if err != nil {
+			panic(fmt.Errorf("INVALID LAST KNOWN GOOD SECRETS KEY: %v", err))
+		}
+	}
+
+	return ret
+}
+
+// save stores the values on disk, if they changed since they were last
+// saved. Values of secret parameters are encrypted, or omitted if no key
+// was provided.
+func (c *lastKnownGood) save(cfg config, values types.ParamValues) error {
+	if paramvalues.Equal(c.saved, values) {
+		return nil
+	}
+
+	file := lastKnownGoodFile{
+		Provider: c.providerName,
+		SavedAt:  time.Now(),
+		Values:   types.ParamValues{},
+	}
+
+	for setName, set := range values {
+		for paramName, value := range set {
+			param, ok := cfg.getParam(setName, paramName)
+			if !ok {
+				continue
+			}
+
+			if !param.secret {
+				paramvalues.Set(file.Values, setName, paramName, value)
+				continue
+			}
+
+			if c.aead == nil {
+				continue
+			}
+
+			if file.Secrets == nil {
+				file.Secrets = types.ParamValues{}
+			}
+
+			sealed, err := c.seal(setName, paramName, value)
+			if err != nil {
+				return err
+			}
+
+			paramvalues.Set(file.Secrets, setName, paramName, sealed)
+		}
+	}
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(c.path, data); err != nil {
+		return err
+	}
+
+	c.saved = values
+	return nil
+}
+
+// load reads the values stored on disk, returning them and when they were
+// stored.
+func (c *lastKnownGood) load() (types.ParamValues, time.Time, error) {
+	data, err := os.ReadFile(c.path)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	var file lastKnownGoodFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, time.Time{}, fmt.Errorf("parsing %s: %w", c.path, err)
+	}
+
+	if file.Provider != c.providerName {
+		return nil, time.Time{}, fmt.Errorf("%s was stored for provider %q",
+			c.path, file.Provider)
+	}
+
+	ret := file.Values
+	if ret == nil {
+		ret = types.ParamValues{}
+	}
+
+	if c.aead != nil {
+		for setName, set := range file.Secrets {
+			for paramName, sealed := range set {
+				value, err := c.open(setName, paramName, sealed)
+				if err != nil {
+					return nil, time.Time{}, fmt.Errorf(
+						"decrypting value of %s.%s from %s: %w",
+						setName, paramName, c.path, err)
+				}
+
+				paramvalues.Set(ret, setName, paramName, value)
+			}
+		}
+	}
+
+	c.saved = ret.Copy()
+	return ret, file.SavedAt, nil
+}
+
+func (c *lastKnownGood) seal(setName, paramName, value string) (string, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := c.aead.Seal(nonce, nonce, []byte(value), secretAD(setName, paramName))
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (c *lastKnownGood) open(setName, paramName, sealed string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		return "", err
+	}
+
+	if len(data) < c.aead.NonceSize() {
+		return "", errors.New("encrypted value is too short")
+	}
+
+	nonce, ciphertext := data[:c.aead.NonceSize()], data[c.aead.NonceSize():]
+	plain, err := c.aead.Open(nil, nonce, ciphertext, secretAD(setName, paramName))
+	if err != nil {
+		return "", err
+	}
+
+	return string(plain), nil
+}
+
+// secretAD binds an encrypted value to the parameter it belongs to.
+func secretAD(setName, paramName string) []byte {
+	return []byte(setName + "." + paramName)
+}
+
+// lastKnownGoodApplied records the values of the providers that are
+// cached, after they were applied, so they are stored by the next call to
+// saveLastKnownGood.
+// Caller must hold the mutex.
+func (p *Parsed) lastKnownGoodApplied() {
+	unsaved := map[int]types.ParamValues{}
+	for ix, u := range p.updaters {
+		if u == nil || u.lastKnownGood == nil || u.fromLastKnownGood() {
+			continue
+		}
+
+		unsaved[ix] = p.protected.values[ix]
+	}
+
+	p.protected.unsavedLastKnownGood = unsaved
+}
+
+// saveLastKnownGood stores the values of the providers that are cached, as
+// recorded by the last refresh. Errors are logged, and do not prevent the
+// configuration from being used.
+// Caller must not hold the mutex; values are written to disk without
+// holding it.
+func (p *Parsed) saveLastKnownGood() {
+	// values are taken while holding lastKnownGoodMutex, so older values
+	// are never written after newer ones
+	p.lastKnownGoodMutex.Lock()
+	defer p.lastKnownGoodMutex.Unlock()
+
+	p.protected.valuesMutex.Lock()
+	unsaved := p.protected.unsavedLastKnownGood
+	p.protected.unsavedLastKnownGood = nil
+	p.protected.valuesMutex.Unlock()
+
+	for _, ix := range slices.Sorted(maps.Keys(unsaved)) {
+		u := p.updaters[ix]
+		if err := u.lastKnownGood.save(p.inferedConfig, unsaved[ix]); err != nil {
+			p.settings.loggerFn.E(fmt.Sprintf(
+				"Storing last known good values of %s: %v", u.providerName, err))
+		}
+	}
+}
+
+// loadLastKnownGood is used when the provider fails to start, returning the
+// values stored for it. watchErr is the error produced when starting the
+// provider, and is returned if there are no stored values.
+func (u *updater) loadLastKnownGood(watchErr error) (types.ParamValues, time.Time, error) {
+	if u.lastKnownGood == nil {
+		return nil, time.Time{}, watchErr
+	}
+
+	values, savedAt, err := u.lastKnownGood.load()
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, time.Time{}, watchErr
+		}
+
+		return nil, time.Time{}, errors.Join(watchErr,
+			fmt.Errorf("reading last known good values: %w", err))
+	}
+
+	u.parsed.settings.loggerFn.E(fmt.Sprintf(
+		"Provider %s failed to start, using last known good values stored at %s: %v",
+		u.providerName, savedAt.Format(time.RFC3339), watchErr))
+
+	return values, savedAt, nil
+}
+
+// safeFileName replaces characters that may not be valid on file names.
+func safeFileName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9',
+			r == '.', r == '-', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+}
+
+// writeFileAtomic writes data to a temporary file and then renames it, so
+// readers never see a partially written file.
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+
+	if err != nil {
+		_ = os.Remove(f.Name())
+	}
+
+	return err
+}
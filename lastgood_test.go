@@ -0,0 +1,183 @@
+//go:build unittest || !integrationtest
+// +build unittest !integrationtest
+
+package proteus_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/simplesurance/proteus"
+	"github.com/simplesurance/proteus/internal/assert"
+	"github.com/simplesurance/proteus/sources"
+	"github.com/simplesurance/proteus/types"
+	"github.com/simplesurance/proteus/xtypes"
+)
+
+type lastKnownGoodParams struct {
+	Host     string
+	Level    *xtypes.Integer[int]
+	Password string `param:",secret"`
+}
+
+func TestLastKnownGood(t *testing.T) {
+	dir := t.TempDir()
+	key := bytes.Repeat([]byte{1}, 32)
+
+	provider := &remoteProvider{values: types.ParamValues{
+		"": {"host": "db.local", "level": "1", "password": "hunter2"},
+	}}
+
+	params := lastKnownGoodParams{}
+	parsed, err := proteus.MustParse(&params,
+		proteus.WithLastKnownGood(proteus.LastKnownGoodOptions{
+			Dir:        dir,
+			Providers:  []sources.Provider{provider},
+			SecretsKey: key,
+		}),
+		proteus.WithProviders(provider))
+	assert.NoErrorNow(t, err)
+
+	// updates are stored too
+	assert.NoErrorNow(t, provider.updater.UpdateWithResult(types.ParamValues{
+		"": {"host": "db.local", "level": "2", "password": "hunter2"},
+	}))
+	stop(t, parsed)
+
+	assertCacheDoesNotContain(t, dir, "hunter2")
+
+	// the provider fails to start, the stored values must be used
+	provider = &remoteProvider{err: errors.New("connection refused")}
+
+	params = lastKnownGoodParams{}
+	parsed, err = proteus.MustParse(&params,
+		proteus.WithLastKnownGood(proteus.LastKnownGoodOptions{
+			Dir:        dir,
+			Providers:  []sources.Provider{provider},
+			SecretsKey: key,
+		}),
+		proteus.WithProviders(provider))
+	assert.NoErrorNow(t, err)
+	defer stop(t, parsed)
+
+	assert.Equal(t, "db.local", params.Host)
+	assert.Equal(t, 2, params.Level.Value())
+	assert.Equal(t, "hunter2", params.Password)
+
+	status := parsed.Providers()
+	assert.EqualNow(t, 1, len(status))
+	assert.True(t, status[0].FromLastKnownGood, "must be using the cache")
+	assert.True(t, !status[0].LastKnownGoodTime.IsZero(), "cache time must be set")
+	assert.True(t, !status[0].Healthy(), "provider must not be healthy")
+
+	for _, state := range parsed.State() {
+		if state.IsDefault {
+			continue
+		}
+
+		assert.Equal(t, "*proteus_test.remoteProvider (last known good)", state.Provider)
+	}
+
+	// when the provider recovers, values are not from the cache anymore
+	assert.NoErrorNow(t, provider.updater.UpdateWithResult(types.ParamValues{
+		"": {"host": "db.local", "level": "3", "password": "hunter2"},
+	}))
+
+	status = parsed.Providers()
+	assert.True(t, !status[0].FromLastKnownGood, "must not be using the cache")
+	assert.True(t, status[0].Healthy(), "provider must be healthy")
+}
+
+func TestLastKnownGoodWithoutSecrets(t *testing.T) {
+	dir := t.TempDir()
+
+	provider := &remoteProvider{values: types.ParamValues{
+		"": {"host": "db.local", "level": "1", "password": "hunter2"},
+	}}
+
+	params := lastKnownGoodParams{}
+	parsed, err := proteus.MustParse(&params,
+		proteus.WithLastKnownGood(proteus.LastKnownGoodOptions{
+			Dir:       dir,
+			Providers: []sources.Provider{provider},
+		}),
+		proteus.WithProviders(provider))
+	assert.NoErrorNow(t, err)
+	stop(t, parsed)
+
+	assertCacheDoesNotContain(t, dir, "password")
+
+	// the password is required, and is not on the cache
+	provider = &remoteProvider{err: errors.New("connection refused")}
+
+	params = lastKnownGoodParams{}
+	parsed, err = proteus.MustParse(&params,
+		proteus.WithLastKnownGood(proteus.LastKnownGoodOptions{
+			Dir:       dir,
+			Providers: []sources.Provider{provider},
+		}),
+		proteus.WithProviders(provider))
+	assert.ErrorNow(t, err)
+	defer stop(t, parsed)
+
+	var violations types.ErrViolations
+	assert.TrueNow(t, errors.As(err, &violations), "error must be violations")
+	assert.Equal(t, "password", violations[0].ParamName)
+}
+
+func TestLastKnownGoodMissing(t *testing.T) {
+	watchErr := errors.New("connection refused")
+	provider := &remoteProvider{err: watchErr}
+
+	params := lastKnownGoodParams{}
+	parsed, err := proteus.MustParse(&params,
+		proteus.WithLastKnownGood(proteus.LastKnownGoodOptions{
+			Dir:       t.TempDir(),
+			Providers: []sources.Provider{provider},
+		}),
+		proteus.WithProviders(provider))
+	assert.ErrorNow(t, err)
+	defer stop(t, parsed)
+
+	assert.True(t, errors.Is(err, watchErr), "error from provider must be returned")
+}
+
+func assertCacheDoesNotContain(t *testing.T, dir, text string) {
+	t.Helper()
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	assert.NoErrorNow(t, err)
+	assert.EqualNow(t, 1, len(files))
+
+	data, err := os.ReadFile(files[0])
+	assert.NoErrorNow(t, err)
+	assert.True(t, !strings.Contains(string(data), text),
+		"cache must not contain "+text)
+}
+
+// remoteProvider is a provider that can fail to start, like providers
+// reading from remote sources.
+type remoteProvider struct {
+	values  types.ParamValues
+	err     error
+	updater sources.Updater
+}
+
+func (p *remoteProvider) IsCommandLineFlag() bool {
+	return false
+}
+
+func (p *remoteProvider) Stop() {
+}
+
+func (p *remoteProvider) Watch(
+	_ sources.Parameters,
+	updater sources.Updater,
+) (types.ParamValues, error) {
+	p.updater = updater
+	return p.values.Copy(), p.err
+}
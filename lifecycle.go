@@ -0,0 +1,223 @@
+package proteus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/simplesurance/proteus/sources"
+	"github.com/simplesurance/proteus/types"
+)
+
+// errStopped is returned when an operation is requested after Stop was
+// called.
+var errStopped = errors.New("configuration was stopped")
+
+// Stop release resources being used. All providers are stopped, and no
+// more updates are accepted. Stop waits until providers that implement
+// sources.ContextStopper terminate, and until all in-flight updates,
+// including the invocation of UpdateFn callbacks of xtypes, finish. If the
+// context is done before that, its error is returned. Reloads started by
+// the signals configured with WithReloadOnSignal are canceled. Providers
+// whose startup timed out are only stopped after their Watch method
+// returns; if that happens after the context is done, they are stopped on
+// the background.
+func (p *Parsed) Stop(ctx context.Context) error {
+	p.lifecycle.mutex.Lock()
+	select {
+	case <-p.lifecycle.stopped:
+		// already stopped
+	default:
+		close(p.lifecycle.stopped)
+	}
+	p.lifecycle.mutex.Unlock()
+
+	var errs []error
+	if err := p.stopReloadOnSignal(ctx); err != nil {
+		errs = append(errs, err)
+	}
+
+	p.stopOverrides()
+
+	for _, u := range p.updaters {
+		if u != nil && u.debounce != nil {
+			u.debounce.stop()
+		}
+	}
+
+	for ix, provider := range p.settings.providers {
+		if !p.waitAbandonedWatch(ctx, ix) {
+			errs = append(errs, fmt.Errorf("waiting for %s to start: %w",
+				providerName(p.updaters[ix], provider), ctx.Err()))
+			continue
+		}
+
+		if err := p.stopProvider(ctx, ix, provider); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		p.lifecycle.inFlight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		errs = append(errs, fmt.Errorf("waiting for in-flight updates: %w", ctx.Err()))
+	}
+
+	return errors.Join(errs...)
+}
+
+func (p *Parsed) stopProvider(ctx context.Context, ix int, provider sources.Provider) error {
+	stopper, ok := provider.(sources.ContextStopper)
+	if !ok {
+		provider.Stop()
+		return nil
+	}
+
+	if err := stopper.StopContext(ctx); err != nil {
+		return fmt.Errorf("stopping %s: %w", providerName(p.updaters[ix], provider), err)
+	}
+
+	return nil
+}
+
+// watchState tracks the Watch method of a provider that is running on the
+// background.
+type watchState struct {
+	done chan struct{} // closed when Watch returns
+
+	// set when Stop did not wait for Watch to return; the provider is
+	// then stopped by the goroutine running Watch. Protected by the
+	// lifecycle mutex.
+	orphaned bool
+}
+
+// waitAbandonedWatch waits until Watch returns for the provider at index
+// ix, if its startup timed out while it was still running. The provider
+// must not be stopped while Watch runs, as Watch initializes it. If the
+// context is done before Watch returns, false is returned, and the
+// provider is stopped when Watch returns.
+func (p *Parsed) waitAbandonedWatch(ctx context.Context, ix int) bool {
+	p.lifecycle.mutex.Lock()
+	state := p.lifecycle.abandoned[ix]
+	p.lifecycle.mutex.Unlock()
+
+	if state == nil {
+		return true
+	}
+
+	select {
+	case <-state.done:
+		return true
+	case <-ctx.Done():
+	}
+
+	p.lifecycle.mutex.Lock()
+	defer p.lifecycle.mutex.Unlock()
+
+	select {
+	case <-state.done:
+		return true
+	default:
+		state.orphaned = true
+		return false
+	}
+}
+
+// beginOperation registers an operation that Stop must wait for. If false
+// is returned, Stop was already called and the operation must not be
+// executed. Otherwise endOperation must be called when the operation
+// finishes.
+func (p *Parsed) beginOperation() bool {
+	p.lifecycle.mutex.Lock()
+	defer p.lifecycle.mutex.Unlock()
+
+	select {
+	case <-p.lifecycle.stopped:
+		return false
+	default:
+	}
+
+	p.lifecycle.inFlight.Add(1)
+	return true
+}
+
+func (p *Parsed) endOperation() {
+	p.lifecycle.inFlight.Done()
+}
+
+// watch starts the provider, respecting the context and the startup timeout.
+func (p *Parsed) watch(
+	ctx context.Context,
+	provider sources.Provider,
+	paramIDs sources.Parameters,
+	updater *updater,
+) (types.ParamValues, error) {
+	if timeout := p.settings.providerStartupTimeout; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	type result struct {
+		values types.ParamValues
+		err    error
+	}
+
+	// buffered, so the goroutine can terminate even if nobody is
+	// waiting for the result anymore
+	resultCh := make(chan result, 1)
+	state := &watchState{done: make(chan struct{})}
+	go func() {
+		var r result
+		if watcher, ok := provider.(sources.ContextWatcher); ok {
+			r.values, r.err = watcher.WatchContext(ctx, paramIDs, updater)
+		} else {
+			r.values, r.err = provider.Watch(paramIDs, updater)
+		}
+
+		resultCh <- r
+
+		p.lifecycle.mutex.Lock()
+		close(state.done)
+		orphaned := state.orphaned
+		p.lifecycle.mutex.Unlock()
+
+		if orphaned {
+			if err := p.stopProvider(context.Background(), updater.providerIndex, provider); err != nil {
+				p.settings.loggerFn.E(err.Error())
+			}
+		}
+	}()
+
+	select {
+	case r := <-resultCh:
+		return r.values, r.err
+	case <-ctx.Done():
+		// Stop must wait for Watch to return before stopping the
+		// provider
+		p.lifecycle.mutex.Lock()
+		if p.lifecycle.abandoned == nil {
+			p.lifecycle.abandoned = map[int]*watchState{}
+		}
+
+		p.lifecycle.abandoned[updater.providerIndex] = state
+		p.lifecycle.mutex.Unlock()
+
+		return nil, fmt.Errorf("provider %s did not start: %w",
+			updater.providerName, ctx.Err())
+	}
+}
+
+func providerName(u *updater, provider sources.Provider) string {
+	if u != nil {
+		return u.providerName
+	}
+
+	return fmt.Sprintf("%T", provider)
+}
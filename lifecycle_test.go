@@ -0,0 +1,210 @@
+//go:build unittest || !integrationtest
+// +build unittest !integrationtest
+
+package proteus_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/simplesurance/proteus"
+	"github.com/simplesurance/proteus/internal/assert"
+	"github.com/simplesurance/proteus/sources"
+	"github.com/simplesurance/proteus/sources/cfgtest"
+	"github.com/simplesurance/proteus/types"
+	"github.com/simplesurance/proteus/xtypes"
+)
+
+func TestProviderStartupTimeout(t *testing.T) {
+	params := struct {
+		Name string
+	}{}
+
+	provider := &slowProvider{delay: time.Minute}
+
+	start := time.Now()
+	parsed, err := proteus.MustParse(&params,
+		proteus.WithProviderStartupTimeout(100*time.Millisecond),
+		proteus.WithProviders(provider))
+	assert.ErrorNow(t, err)
+	defer stop(t, parsed)
+
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), "must time out")
+	assert.True(t, time.Since(start) < 10*time.Second, "must not wait for the provider")
+
+	// the provider observes the cancellation asynchronously
+	assert.Eventually(t, 2*time.Second, provider.ctxCanceled.Load,
+		"provider context must be canceled")
+}
+
+// TestStopWaitsAbandonedWatch asserts that providers whose startup timed
+// out are only stopped after their Watch method returns.
+func TestStopWaitsAbandonedWatch(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		stopTimeout time.Duration
+	}{
+		{name: "waits", stopTimeout: 5 * time.Second},
+		{name: "stop times out", stopTimeout: 10 * time.Millisecond},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			params := struct {
+				Name string
+			}{}
+
+			provider := &stubbornProvider{delay: 300 * time.Millisecond}
+			parsed, err := proteus.MustParse(&params,
+				proteus.WithProviderStartupTimeout(50*time.Millisecond),
+				proteus.WithProviders(provider))
+			assert.ErrorNow(t, err)
+
+			ctx, cancel := context.WithTimeout(context.Background(), tc.stopTimeout)
+			defer cancel()
+
+			err = parsed.Stop(ctx)
+			if tc.stopTimeout < provider.delay {
+				assert.ErrorNow(t, err)
+			} else {
+				assert.NoErrorNow(t, err)
+			}
+
+			// when Stop does not wait, the provider is stopped when
+			// Watch returns
+			assert.Eventually(t, 2*time.Second, provider.stopped.Load,
+				"provider must be stopped")
+
+			assert.True(t, provider.stoppedAfterWatch.Load(),
+				"provider must be stopped after Watch returns")
+		})
+	}
+}
+
+func TestMustParseContextCanceled(t *testing.T) {
+	params := struct {
+		Name string
+	}{}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	parsed, err := proteus.MustParseContext(ctx, &params,
+		proteus.WithProviders(&slowProvider{delay: time.Minute}))
+	assert.ErrorNow(t, err)
+	defer stop(t, parsed)
+
+	assert.True(t, errors.Is(err, context.Canceled), "must be canceled")
+}
+
+// TestStopWaitsInFlight asserts that Stop waits for UpdateFn callbacks that
+// are running, and that updates are refused after Stop.
+func TestStopWaitsInFlight(t *testing.T) {
+	var callbackFinished atomic.Bool
+	callbackStarted := make(chan struct{})
+
+	params := struct {
+		Name *xtypes.String
+	}{
+		Name: &xtypes.String{
+			UpdateFn: func(s string) {
+				if s != "slow" {
+					return
+				}
+
+				close(callbackStarted)
+				time.Sleep(200 * time.Millisecond)
+				callbackFinished.Store(true)
+			},
+		},
+	}
+
+	provider := cfgtest.New(types.ParamValues{"": {"name": "initial"}})
+
+	parsed, err := proteus.MustParse(&params, proteus.WithProviders(provider))
+	assert.NoErrorNow(t, err)
+
+	go provider.Update("", "name", ptr("slow"))
+	<-callbackStarted
+
+	assert.NoErrorNow(t, parsed.Stop(context.Background()))
+	assert.True(t, callbackFinished.Load(), "Stop must wait for the callback")
+
+	err = provider.UpdateWithResult("", "name", ptr("after stop"))
+	assert.ErrorNow(t, err)
+	assert.Equal(t, "slow", params.Name.Value())
+}
+
+// slowProvider is a provider that takes the specified time to start,
+// unless its context is canceled.
+type slowProvider struct {
+	delay       time.Duration
+	ctxCanceled atomic.Bool
+}
+
+var _ sources.ContextWatcher = &slowProvider{}
+
+func (p *slowProvider) IsCommandLineFlag() bool {
+	return false
+}
+
+func (p *slowProvider) Stop() {
+}
+
+func (p *slowProvider) Watch(
+	paramIDs sources.Parameters,
+	updater sources.Updater,
+) (types.ParamValues, error) {
+	return p.WatchContext(context.Background(), paramIDs, updater)
+}
+
+func (p *slowProvider) WatchContext(
+	ctx context.Context,
+	_ sources.Parameters,
+	_ sources.Updater,
+) (types.ParamValues, error) {
+	select {
+	case <-time.After(p.delay):
+		return types.ParamValues{"": {"name": "slow"}}, nil
+	case <-ctx.Done():
+		p.ctxCanceled.Store(true)
+		return nil, ctx.Err()
+	}
+}
+
+// stubbornProvider is a provider that takes the specified time to start,
+// ignoring cancellation.
+type stubbornProvider struct {
+	delay             time.Duration
+	watched           atomic.Bool
+	stopped           atomic.Bool
+	stoppedAfterWatch atomic.Bool
+}
+
+func (p *stubbornProvider) IsCommandLineFlag() bool {
+	return false
+}
+
+func (p *stubbornProvider) Stop() {
+	p.stoppedAfterWatch.Store(p.watched.Load())
+	p.stopped.Store(true)
+}
+
+func (p *stubbornProvider) Watch(
+	sources.Parameters,
+	sources.Updater,
+) (types.ParamValues, error) {
+	time.Sleep(p.delay)
+	p.watched.Store(true)
+	return types.ParamValues{"": {"name": "slow"}}, nil
+}
+
+func stop(t *testing.T, parsed *proteus.Parsed) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	assert.NoError(t, parsed.Stop(ctx))
+}
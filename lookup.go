@@ -0,0 +1,127 @@
+package proteus
+
+import (
+	"fmt"
+	"iter"
+	"reflect"
+
+	"github.com/simplesurance/proteus/types"
+)
+
+// Get returns the effective value of a parameter, as a string, allowing code
+// without access to the configuration struct to read it. When no provider
+// provides a value, the default value is returned. Values of secret
+// parameters are redacted; use GetUnredacted to read them. If the parameter
+// does not exist, false is returned.
+func (p *Parsed) Get(setName, paramName string) (string, bool) {
+	param, ok := p.inferedConfig.getParam(setName, paramName)
+	if !ok {
+		return "", false
+	}
+
+	p.protected.valuesMutex.Lock()
+	defer p.protected.valuesMutex.Unlock()
+
+	if value := p.desiredValue(p.valuesInterpolator(), setName, paramName); value != nil {
+		return param.redactedValue(value)(), true
+	}
+
+	return param.redactedDefaultValue(), true
+}
+
+// GetUnredacted is the same as Get, but values are never redacted. Use it
+// with care, to avoid leaking secrets.
+func (p *Parsed) GetUnredacted(setName, paramName string) (string, bool) {
+	param, ok := p.inferedConfig.getParam(setName, paramName)
+	if !ok {
+		return "", false
+	}
+
+	p.protected.valuesMutex.Lock()
+	defer p.protected.valuesMutex.Unlock()
+
+	if value := p.desiredValue(p.valuesInterpolator(), setName, paramName); value != nil {
+		return *value, true
+	}
+
+	ret, err := param.getDefaultFn()
+	if err != nil {
+		return "", false
+	}
+
+	return ret, true
+}
+
+// IsSet returns true if a value for the parameter is provided, and false if
+// the default value is being used or the parameter does not exist.
+func (p *Parsed) IsSet(setName, paramName string) bool {
+	p.protected.valuesMutex.Lock()
+	defer p.protected.valuesMutex.Unlock()
+
+	return p.desiredValue(p.valuesInterpolator(), setName, paramName) != nil
+}
+
+// Params iterates over all parameters, sorted by set and parameter name,
+// providing their current state. Values are redacted. See State.
+func (p *Parsed) Params() iter.Seq[ParamState] {
+	return func(yield func(ParamState) bool) {
+		for _, state := range p.State() {
+			if !yield(state) {
+				return
+			}
+		}
+	}
+}
+
+// GetAs returns the value of a parameter, as it is on the configuration
+// struct. T must be the type of the field on the configuration struct or,
+// for xtypes, the type returned by their Value() method. For example:
+//
+//	port, err := proteus.GetAs[uint16](parsed, "http", "port")
+//
+// Like with the configuration struct, only xtypes are updated while the
+// application is running. Values of secret parameters can't be read with
+// this function; use GetUnredacted.
+func GetAs[T any](p *Parsed, setName, paramName string) (T, error) {
+	var ret T
+
+	param, ok := p.inferedConfig.getParam(setName, paramName)
+	if !ok {
+		return ret, types.ErrViolations{{
+			SetName:   setName,
+			ParamName: paramName,
+			Message:   "parameter is not expected by the application",
+		}}
+	}
+
+	if param.secret {
+		return ret, types.ErrViolations{{
+			SetName:   setName,
+			ParamName: paramName,
+			Message:   "parameter is secret, its value can only be read with GetUnredacted",
+		}}
+	}
+
+	if param.valueFn == nil {
+		return ret, types.ErrViolations{{
+			SetName:   setName,
+			ParamName: paramName,
+			Message:   "value of the parameter is not available",
+		}}
+	}
+
+	p.protected.valuesMutex.Lock()
+	value := param.valueFn()
+	p.protected.valuesMutex.Unlock()
+
+	ret, ok = value.(T)
+	if !ok {
+		return ret, types.ErrViolations{{
+			SetName:   setName,
+			ParamName: paramName,
+			Message:   fmt.Sprintf("parameter has type %T, not %s", value, reflect.TypeFor[T]()),
+		}}
+	}
+
+	return ret, nil
+}
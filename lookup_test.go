@@ -0,0 +1,88 @@
+//go:build unittest || !integrationtest
+// +build unittest !integrationtest
+
+package proteus_test
+
+import (
+	"testing"
+
+	"github.com/simplesurance/proteus"
+	"github.com/simplesurance/proteus/internal/assert"
+	"github.com/simplesurance/proteus/sources/cfgtest"
+	"github.com/simplesurance/proteus/types"
+	"github.com/simplesurance/proteus/xtypes"
+)
+
+func TestLookup(t *testing.T) {
+	params := struct {
+		DB struct {
+			Host     string
+			Port     uint16 `param:",optional" param_desc:"Database port"`
+			Password string `param:",secret"`
+		}
+		Level *xtypes.Integer[int]
+	}{}
+	params.DB.Port = 5432
+
+	provider := cfgtest.New(types.ParamValues{
+		"db": {"host": "db.local", "password": "hunter2"},
+		"":   {"level": "1"},
+	})
+
+	parsed, err := proteus.MustParse(&params, proteus.WithProviders(provider))
+	assert.NoErrorNow(t, err)
+	defer stop(t, parsed)
+
+	value, ok := parsed.Get("db", "host")
+	assert.True(t, ok, "parameter must exist")
+	assert.Equal(t, "db.local", value)
+
+	value, ok = parsed.Get("db", "port")
+	assert.True(t, ok, "parameter must exist")
+	assert.Equal(t, "5432", value)
+	assert.True(t, !parsed.IsSet("db", "port"), "port must use the default value")
+	assert.True(t, parsed.IsSet("db", "host"), "host must be set")
+
+	_, ok = parsed.Get("db", "unknown")
+	assert.True(t, !ok, "parameter must not exist")
+
+	// secrets are only available unredacted when explicitly requested
+	value, _ = parsed.Get("db", "password")
+	assert.Equal(t, "<redacted>", value)
+
+	value, _ = parsed.GetUnredacted("db", "password")
+	assert.Equal(t, "hunter2", value)
+
+	_, err = proteus.GetAs[string](parsed, "db", "password")
+	assert.Error(t, err)
+
+	// typed access
+	port, err := proteus.GetAs[uint16](parsed, "db", "port")
+	assert.NoErrorNow(t, err)
+	assert.Equal(t, uint16(5432), port)
+
+	_, err = proteus.GetAs[int](parsed, "db", "port")
+	assert.Error(t, err)
+
+	level, err := proteus.GetAs[int](parsed, "", "level")
+	assert.NoErrorNow(t, err)
+	assert.Equal(t, 1, level)
+
+	assert.NoErrorNow(t, provider.UpdateWithResult("", "level", ptr("2")))
+	level, err = proteus.GetAs[int](parsed, "", "level")
+	assert.NoErrorNow(t, err)
+	assert.Equal(t, 2, level)
+
+	// iteration
+	found := map[string]proteus.ParamState{}
+	for state := range parsed.Params() {
+		found[state.SetName+"."+state.ParamName] = state
+	}
+
+	portState := found["db.port"]
+	assert.Equal(t, "uint16", portState.Type)
+	assert.Equal(t, "Database port", portState.Description)
+	assert.True(t, portState.Optional, "port must be optional")
+	assert.True(t, portState.IsDefault, "port must use the default value")
+	assert.Equal(t, "<redacted>", found["db.password"].Value)
+}
@@ -0,0 +1,77 @@
+package proteus
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/simplesurance/proteus/types"
+)
+
+// recordUpdate registers the result of processing an update from a provider
+// on the history and on the metrics.
+func (p *Parsed) recordUpdate(
+	providerName string,
+	previous, current types.ParamValues,
+	err error,
+) {
+	p.recordHistory(providerName, previous, current, err)
+	p.recordMetrics(providerName, err)
+}
+
+// recordMetrics registers the result of processing an update from a provider
+// on the metrics.
+func (p *Parsed) recordMetrics(providerName string, err error) {
+	if err == nil {
+		p.settings.metrics.UpdateApplied(providerName)
+		return
+	}
+
+	p.settings.metrics.UpdateRejected(providerName)
+	p.recordViolations(err)
+}
+
+// recordViolations registers on the metrics the parameters that caused
+// the error, if any.
+func (p *Parsed) recordViolations(err error) {
+	var violations types.ErrViolations
+	if !errors.As(err, &violations) {
+		return
+	}
+
+	for _, violation := range violations {
+		if violation.ParamName != "" {
+			p.settings.metrics.Violation(violation.SetName, violation.ParamName)
+		}
+	}
+}
+
+// configHash computes a hash that identifies the effective configuration.
+// Secret values are redacted before computing the hash, to avoid leaking
+// information about them.
+//
+// Caller must hold the mutex.
+func (p *Parsed) configHash() string {
+	merged := p.mergeValues()
+
+	h := sha256.New()
+	for _, setName := range mapKeysSorted(p.inferedConfig) {
+		set := p.inferedConfig[setName]
+
+		for _, paramName := range mapKeysSorted(set.fields) {
+			value := merged.Get(setName, paramName)
+			if value == nil {
+				// default values are not part of the provided
+				// configuration, and can't change without
+				// restarting the application
+				continue
+			}
+
+			redacted := set.fields[paramName].redactedValue(value)()
+			fmt.Fprintf(h, "%q.%q=%q\n", setName, paramName, redacted)
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
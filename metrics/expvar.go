@@ -0,0 +1,82 @@
+package metrics
+
+import (
+	"expvar"
+	"time"
+)
+
+// Expvar is a Recorder that publishes the metrics using the expvar package.
+// The following variables are published on a map with the name provided
+// to NewExpvar:
+//
+//	updates_received      map of provider name => counter
+//	updates_applied       map of provider name => counter
+//	updates_rejected      map of provider name => counter
+//	violations            map of parameter ("set.param") => counter
+//	last_applied_unix     when the configuration was last applied
+//	config_hash           hash of the effective configuration
+type Expvar struct {
+	received        *expvar.Map
+	applied         *expvar.Map
+	rejected        *expvar.Map
+	violations      *expvar.Map
+	lastAppliedUnix *expvar.Int
+	configHash      *expvar.String
+}
+
+var _ Recorder = &Expvar{}
+
+// NewExpvar creates a Recorder that publishes metrics with expvar, under
+// the provided name. Like expvar.Publish, it panics if the name is already
+// in use.
+func NewExpvar(name string) *Expvar {
+	ret := &Expvar{
+		received:        new(expvar.Map),
+		applied:         new(expvar.Map),
+		rejected:        new(expvar.Map),
+		violations:      new(expvar.Map),
+		lastAppliedUnix: new(expvar.Int),
+		configHash:      new(expvar.String),
+	}
+
+	root := expvar.NewMap(name)
+	root.Set("updates_received", ret.received)
+	root.Set("updates_applied", ret.applied)
+	root.Set("updates_rejected", ret.rejected)
+	root.Set("violations", ret.violations)
+	root.Set("last_applied_unix", ret.lastAppliedUnix)
+	root.Set("config_hash", ret.configHash)
+
+	return ret
+}
+
+// UpdateReceived increments the counter of received updates of the provider.
+func (e *Expvar) UpdateReceived(provider string) {
+	e.received.Add(provider, 1)
+}
+
+// UpdateApplied increments the counter of applied updates of the provider.
+func (e *Expvar) UpdateApplied(provider string) {
+	e.applied.Add(provider, 1)
+}
+
+// UpdateRejected increments the counter of rejected updates of the provider.
+func (e *Expvar) UpdateRejected(provider string) {
+	e.rejected.Add(provider, 1)
+}
+
+// Violation increments the counter of violations of the parameter.
+func (e *Expvar) Violation(setName, paramName string) {
+	id := paramName
+	if setName != "" {
+		id = setName + "." + paramName
+	}
+
+	e.violations.Add(id, 1)
+}
+
+// ConfigApplied records when the configuration was applied and its hash.
+func (e *Expvar) ConfigApplied(at time.Time, hash string) {
+	e.lastAppliedUnix.Set(at.Unix())
+	e.configHash.Set(hash)
+}
@@ -0,0 +1,46 @@
+//go:build unittest || !integrationtest
+// +build unittest !integrationtest
+
+package metrics_test
+
+import (
+	"encoding/json"
+	"expvar"
+	"testing"
+	"time"
+
+	"github.com/simplesurance/proteus/internal/assert"
+	"github.com/simplesurance/proteus/metrics"
+)
+
+func TestExpvar(t *testing.T) {
+	recorder := metrics.NewExpvar("proteus_test")
+
+	recorder.UpdateReceived("provider")
+	recorder.UpdateReceived("provider")
+	recorder.UpdateApplied("provider")
+	recorder.UpdateRejected("provider")
+	recorder.Violation("db", "host")
+	recorder.Violation("", "port")
+	recorder.ConfigApplied(time.Unix(42, 0), "abc")
+
+	var have struct {
+		Received        map[string]int `json:"updates_received"`
+		Applied         map[string]int `json:"updates_applied"`
+		Rejected        map[string]int `json:"updates_rejected"`
+		Violations      map[string]int `json:"violations"`
+		LastAppliedUnix int64          `json:"last_applied_unix"`
+		ConfigHash      string         `json:"config_hash"`
+	}
+
+	err := json.Unmarshal([]byte(expvar.Get("proteus_test").String()), &have)
+	assert.NoErrorNow(t, err)
+
+	assert.Equal(t, 2, have.Received["provider"])
+	assert.Equal(t, 1, have.Applied["provider"])
+	assert.Equal(t, 1, have.Rejected["provider"])
+	assert.Equal(t, 1, have.Violations["db.host"])
+	assert.Equal(t, 1, have.Violations["port"])
+	assert.Equal(t, int64(42), have.LastAppliedUnix)
+	assert.Equal(t, "abc", have.ConfigHash)
+}
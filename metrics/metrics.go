@@ -0,0 +1,53 @@
+// Package metrics has the types and code used to instrument configuration
+// updates on proteus. Design goals:
+//
+// - no external libraries
+// - allow users to plug in any monitoring system
+package metrics
+
+import "time"
+
+// Recorder receives events about configuration updates, allowing them to be
+// exported to a monitoring system. Methods may be called concurrently.
+type Recorder interface {
+	// UpdateReceived is called each time a provider sends values.
+	UpdateReceived(provider string)
+
+	// UpdateApplied is called when the values sent by a provider were
+	// accepted.
+	UpdateApplied(provider string)
+
+	// UpdateRejected is called when the values sent by a provider were
+	// rejected.
+	UpdateRejected(provider string)
+
+	// Violation is called for each parameter that caused values to be
+	// rejected. setName is empty for parameters not in a set.
+	Violation(setName, paramName string)
+
+	// ConfigApplied is called each time a valid configuration is applied.
+	// The hash identifies the effective configuration, allowing to detect
+	// drift between replicas of an application. Values of secrets are not
+	// used to compute the hash.
+	ConfigApplied(at time.Time, hash string)
+}
+
+// Nop is a Recorder that discards all events.
+type Nop struct{}
+
+var _ Recorder = Nop{}
+
+// UpdateReceived does nothing.
+func (Nop) UpdateReceived(string) {}
+
+// UpdateApplied does nothing.
+func (Nop) UpdateApplied(string) {}
+
+// UpdateRejected does nothing.
+func (Nop) UpdateRejected(string) {}
+
+// Violation does nothing.
+func (Nop) Violation(string, string) {}
+
+// ConfigApplied does nothing.
+func (Nop) ConfigApplied(time.Time, string) {}
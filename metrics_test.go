@@ -0,0 +1,133 @@
+//go:build unittest || !integrationtest
+// +build unittest !integrationtest
+
+package proteus_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/simplesurance/proteus"
+	"github.com/simplesurance/proteus/internal/assert"
+	"github.com/simplesurance/proteus/sources/cfgtest"
+	"github.com/simplesurance/proteus/types"
+	"github.com/simplesurance/proteus/xtypes"
+)
+
+func TestMetrics(t *testing.T) {
+	params := struct {
+		Level *xtypes.Integer[int]
+		Token string `param:",secret"`
+	}{}
+
+	provider := cfgtest.New(types.ParamValues{
+		"": {"level": "1", "token": "secret"},
+	})
+
+	recorder := &testRecorder{counters: map[string]int{}}
+	parsed, err := proteus.MustParse(&params,
+		proteus.WithMetrics(recorder),
+		proteus.WithProviders(provider))
+	assert.NoErrorNow(t, err)
+	defer stop(t, parsed)
+
+	const providerName = "*cfgtest.TestProvider"
+	initialHash := recorder.hash
+	assert.True(t, initialHash != "", "hash must be set")
+
+	assert.NoErrorNow(t, provider.UpdateWithResult("", "level", ptr("2")))
+	assert.ErrorNow(t, provider.UpdateWithResult("", "level", ptr("invalid")))
+
+	assert.Equal(t, 3, recorder.get("received:"+providerName))
+	assert.Equal(t, 2, recorder.get("applied:"+providerName))
+	assert.Equal(t, 1, recorder.get("rejected:"+providerName))
+	assert.Equal(t, 1, recorder.get("violation:.level"))
+	assert.True(t, recorder.hash != initialHash, "hash must change when the configuration changes")
+
+	// the hash must be the same for the same configuration
+	assert.NoErrorNow(t, provider.UpdateWithResult("", "level", ptr("1")))
+	assert.Equal(t, initialHash, recorder.hash)
+}
+
+// TestMetricsConfigAppliedAfterUpdate asserts that the configuration is
+// reported as applied only after the xtypes are updated.
+func TestMetricsConfigAppliedAfterUpdate(t *testing.T) {
+	params := struct {
+		Level *xtypes.Integer[int]
+	}{}
+
+	provider := cfgtest.New(types.ParamValues{
+		"": {"level": "1"},
+	})
+
+	var appliedLevels []int
+	recorder := &testRecorder{
+		counters: map[string]int{},
+		onConfigApplied: func() {
+			if params.Level != nil {
+				appliedLevels = append(appliedLevels, params.Level.Value())
+			}
+		},
+	}
+
+	parsed, err := proteus.MustParse(&params,
+		proteus.WithMetrics(recorder),
+		proteus.WithProviders(provider))
+	assert.NoErrorNow(t, err)
+	defer stop(t, parsed)
+
+	assert.NoErrorNow(t, provider.UpdateWithResult("", "level", ptr("2")))
+	assert.Equal(t, 2, len(appliedLevels))
+	assert.Equal(t, 1, appliedLevels[0])
+	assert.Equal(t, 2, appliedLevels[1])
+}
+
+type testRecorder struct {
+	mutex    sync.Mutex
+	counters map[string]int
+	hash     string
+
+	// onConfigApplied, when set, is called by ConfigApplied
+	onConfigApplied func()
+}
+
+func (r *testRecorder) inc(key string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.counters[key]++
+}
+
+func (r *testRecorder) get(key string) int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.counters[key]
+}
+
+func (r *testRecorder) UpdateReceived(provider string) {
+	r.inc("received:" + provider)
+}
+
+func (r *testRecorder) UpdateApplied(provider string) {
+	r.inc("applied:" + provider)
+}
+
+func (r *testRecorder) UpdateRejected(provider string) {
+	r.inc("rejected:" + provider)
+}
+
+func (r *testRecorder) Violation(setName, paramName string) {
+	r.inc("violation:" + setName + "." + paramName)
+}
+
+func (r *testRecorder) ConfigApplied(_ time.Time, hash string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.hash = hash
+	if r.onConfigApplied != nil {
+		r.onConfigApplied()
+	}
+}
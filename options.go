@@ -2,8 +2,11 @@ package proteus
 
 import (
 	"io"
+	"os"
 	"strings"
+	"time"
 
+	"github.com/simplesurance/proteus/metrics"
 	"github.com/simplesurance/proteus/plog"
 	"github.com/simplesurance/proteus/sources"
 )
@@ -23,6 +26,53 @@ type settings struct {
 
 	// version (aka --version)
 	version string
+
+	// signals that trigger reloading the configuration
+	reloadSignals []os.Signal
+
+	// what to do when providers send values for unknown parameters
+	unknownParamPolicy UnknownParamPolicy
+
+	// number of updates kept in the history
+	historySize int
+
+	metrics metrics.Recorder
+
+	// how long each provider can take to start
+	providerStartupTimeout time.Duration
+
+	// how updates from providers are debounced
+	debounce         DebounceOptions
+	providerDebounce []providerDebounce
+
+	// where the last known good values from providers are stored; nil
+	// when disabled
+	lastKnownGood *LastKnownGoodOptions
+
+	// sources from where secrets can't be read
+	secretsForbiddenOn []string
+
+	// how secrets are scrubbed; nil when disabled
+	scrub *ScrubOptions
+
+	// whether references on values are expanded
+	interpolation bool
+}
+
+type providerDebounce struct {
+	provider sources.Provider
+	opts     DebounceOptions
+}
+
+// debounceFor returns how updates from the provider must be debounced.
+func (s *settings) debounceFor(provider sources.Provider) DebounceOptions {
+	for _, pd := range s.providerDebounce {
+		if pd.provider == provider {
+			return pd.opts
+		}
+	}
+
+	return s.debounce
 }
 
 func (s *settings) apply(options ...Option) {
@@ -95,6 +145,181 @@ func WithVersion(version string) Option {
 	}
 }
 
+// WithReloadOnSignal instructs proteus to reload the configuration when the
+// application receives one of the provided signals. Reloading has the same
+// effect as calling Parsed.Reload(). Example:
+//
+//	proteus.WithReloadOnSignal(syscall.SIGHUP)
+//
+// Signals stop being handled when Parsed.Stop is called.
+func WithReloadOnSignal(signals ...os.Signal) Option {
+	return func(s *settings) {
+		s.reloadSignals = signals
+	}
+}
+
+// WithUnknownParamPolicy specifies what proteus does when a provider provides
+// values for a parameter or a parameter set that the application did not
+// register. See UnknownParamPolicy for details. If not specified,
+// UnknownParamReject is used.
+func WithUnknownParamPolicy(policy UnknownParamPolicy) Option {
+	return func(s *settings) {
+		s.unknownParamPolicy = policy
+	}
+}
+
+// WithHistory instructs proteus to keep in memory information about the
+// most recent size updates received from providers, allowing to answer what
+// was the value of a parameter at a given time. The history is available
+// with Parsed.History(). Values are redacted.
+func WithHistory(size int) Option {
+	return func(s *settings) {
+		s.historySize = size
+	}
+}
+
+// WithMetrics provides a recorder for metrics about configuration updates,
+// like the number of updates received from each provider and how many were
+// rejected. See metrics.NewExpvar for a recorder that publishes them with
+// expvar. By default metrics are not recorded.
+func WithMetrics(m metrics.Recorder) Option {
+	return func(s *settings) {
+		s.metrics = m
+	}
+}
+
+// WithProviderStartupTimeout limits how long each provider can take to start
+// when parsing the configuration. Providers that do not start on time
+// cause MustParse to return an error. Providers that implement
+// sources.ContextWatcher get a context that is canceled when the timeout
+// expires; other providers are not interrupted, but proteus stops waiting
+// for them. By default there is no timeout.
+func WithProviderStartupTimeout(timeout time.Duration) Option {
+	return func(s *settings) {
+		s.providerStartupTimeout = timeout
+	}
+}
+
+// WithDebounce instructs proteus to debounce and coalesce updates from all
+// providers. When a provider sends many updates in a short time, for example
+// while a file is being written or while keys of a key/value store are
+// changed one by one, only the most recent values are validated and applied,
+// after no new update was received for opts.MinInterval. See DebounceOptions
+// for details.
+//
+// Providers calling sources.Updater.Update are not blocked by the debounce;
+// calls to sources.Updater.UpdateWithResult block until the coalesced update
+// is applied, and return its result.
+func WithDebounce(opts DebounceOptions) Option {
+	return func(s *settings) {
+		s.debounce = opts
+	}
+}
+
+// WithProviderDebounce is the same as WithDebounce, but only applies to
+// updates from the specified provider, taking precedence over WithDebounce.
+// Debouncing can be disabled for a provider by providing a zero
+// DebounceOptions.
+func WithProviderDebounce(provider sources.Provider, opts DebounceOptions) Option {
+	return func(s *settings) {
+		s.providerDebounce = append(s.providerDebounce, providerDebounce{
+			provider: provider,
+			opts:     opts,
+		})
+	}
+}
+
+// WithLastKnownGood instructs proteus to store on disk the last values from
+// providers that resulted in a valid configuration. When a provider fails to
+// start, the stored values are used instead, allowing the application to
+// start with the configuration it had before. Values used this way are
+// identified on Parsed.State() and Parsed.Providers(). See
+// LastKnownGoodOptions for details.
+func WithLastKnownGood(opts LastKnownGoodOptions) Option {
+	return func(s *settings) {
+		s.lastKnownGood = &opts
+	}
+}
+
+// WithSecretsForbiddenOn forbids reading secret parameters from the
+// specified sources. For example, secrets provided as command-line flags can
+// be seen by other users with "ps" and are stored in the shell history;
+// this can be prevented with:
+//
+//	proteus.WithSecretsForbiddenOn("flags")
+//
+// Sources are identified as described in sources.SourceNamer; the "cfg"
+// prefix is optional. Values for secrets provided by forbidden sources
+// result in violations.
+func WithSecretsForbiddenOn(sourceNames ...string) Option {
+	return func(s *settings) {
+		for _, name := range sourceNames {
+			s.secretsForbiddenOn = append(s.secretsForbiddenOn, sources.NormalizeSourceName(name))
+		}
+	}
+}
+
+// WithSecretScrubbing instructs proteus to remove secrets from their sources
+// after the configuration is successfully parsed, when the provider
+// supports it (see sources.Scrubber). For example, environment variables
+// that provided secrets are unset, so they are not inherited by child
+// processes and do not show up in crash dumps.
+//
+// Secrets provided as command-line flags can't be scrubbed; a warning is
+// logged about them, or parsing fails, depending on opts. What was done is
+// available with Parsed.ScrubReport().
+func WithSecretScrubbing(opts ScrubOptions) Option {
+	return func(s *settings) {
+		s.scrub = &opts
+	}
+}
+
+// WithInterpolation enables expanding references on values, after the value
+// for each parameter is selected from the providers. The references are:
+//
+//   - ${set.param}: the value of the parameter "param" on the parameter set
+//     "set"; parameters on the root set are referenced as ${param};
+//   - ${env:NAME}: the value of the environment variable NAME.
+//
+// A literal "${" is written as "$${". Referenced parameters are expanded
+// recursively; references that form a cycle, that point to unknown
+// parameters or unset environment variables make the configuration
+// invalid. Secrets can only be referenced by parameters that are also
+// secrets.
+//
+// Values are expanded again every time the configuration is updated, so a
+// parameter referencing another is updated when the referenced value
+// changes. As usual, only xtypes are updated after parsing.
+func WithInterpolation() Option {
+	return func(s *settings) {
+		s.interpolation = true
+	}
+}
+
+// UnknownParamPolicy defines how values for parameters that the application
+// did not register are handled. Providers like cfgenv and cfgflags already
+// refuse unknown parameters, but providers reading from remote sources, like
+// a key/value store, may not.
+type UnknownParamPolicy int
+
+const (
+	// UnknownParamReject rejects the complete update that contains unknown
+	// parameters. The values previously provided by the provider are kept.
+	// When the unknown parameters are provided during the initial parsing,
+	// MustParse returns an error.
+	UnknownParamReject UnknownParamPolicy = iota
+
+	// UnknownParamIgnore drops the unknown parameters, and uses the
+	// remaining values from the update. The dropped parameters are
+	// logged with debug severity, and are available on
+	// ProviderStatus.UnknownParams.
+	UnknownParamIgnore
+
+	// UnknownParamWarn is the same as UnknownParamIgnore, but logs each
+	// parameter that was dropped with warning severity.
+	UnknownParamWarn
+)
+
 // ValueFormattingOptions specifies how values of parameters are "trimmed".
 type ValueFormattingOptions struct {
 	// TrimSpace instructs proteus to trim leading and trailing spaces from
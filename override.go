@@ -0,0 +1,297 @@
+package proteus
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/simplesurance/proteus/types"
+)
+
+// overrideProviderName identifies values set with Parsed.Override() on
+// provenance information and on the history.
+const overrideProviderName = "override"
+
+// maxOverrideExpireRetryInterval limits how long it takes to try again to
+// remove an expired override, when the configuration without it was
+// invalid.
+const maxOverrideExpireRetryInterval = time.Minute
+
+// Override sets the value of a parameter, taking priority over the values
+// from all providers. This allows changing parameters at runtime, for
+// example during an incident, without redeploying the application or
+// changing its configuration sources.
+//
+// The override is validated like any other update. If the resulting
+// configuration is invalid the override is rejected and the validation
+// error is returned. Like with any other update, only xtypes are updated
+// while the application is running. Overrides are identified as the
+// "override" source, so they are also subject to the "sources" option of
+// the "param" tag and to WithSecretsForbiddenOn.
+//
+// If ttl is positive, the override is automatically removed after it
+// expires. Otherwise, it remains until ClearOverride is called. If the
+// configuration without an expired override is invalid, the override is
+// kept, the error is reported on ParamState.OverrideExpireError, and
+// removing it is retried periodically.
+func (p *Parsed) Override(setName, paramName, value string, ttl time.Duration) error {
+	param, ok := p.inferedConfig.getParam(setName, paramName)
+	if !ok {
+		return types.ErrViolations{{
+			SetName:   setName,
+			ParamName: paramName,
+			Message:   "parameter is not expected by the application",
+		}}
+	}
+
+	if param.isSpecial {
+		return types.ErrViolations{{
+			SetName:   setName,
+			ParamName: paramName,
+			Message:   "special parameters can't be overridden",
+		}}
+	}
+
+	if !p.sourceAllowed(param, overrideProviderName) {
+		return types.ErrViolations{p.sourceViolation(
+			setName, paramName, param, overrideProviderName, overrideProviderName)}
+	}
+
+	if !p.beginOperation() {
+		return errStopped
+	}
+	defer p.endOperation()
+
+	p.settings.metrics.UpdateReceived(overrideProviderName)
+	value = p.settings.valueFormatting.apply(value)
+
+	// runs after the mutex is released
+	defer p.saveLastKnownGood()
+
+	p.protected.valuesMutex.Lock()
+	defer p.protected.valuesMutex.Unlock()
+
+	previous := p.protected.overrides.values()
+	previousEntry, hadPrevious := p.protected.overrides.entries[setName][paramName]
+
+	gen := p.protected.overrides.add(setName, paramName, value)
+	current := p.protected.overrides.values()
+
+	if err := p.refresh(false); err != nil {
+		if hadPrevious {
+			p.protected.overrides.put(setName, paramName, previousEntry)
+		} else {
+			p.protected.overrides.remove(setName, paramName)
+		}
+
+		p.recordUpdate(overrideProviderName, previous, current, err)
+		return err
+	}
+
+	if hadPrevious && previousEntry.timer != nil {
+		previousEntry.timer.Stop()
+	}
+
+	p.recordUpdate(overrideProviderName, previous, current, nil)
+
+	if ttl > 0 {
+		p.protected.overrides.setTimer(setName, paramName, ttl, time.AfterFunc(ttl, func() {
+			p.expireOverride(setName, paramName, gen)
+		}))
+	}
+
+	return nil
+}
+
+// ClearOverride removes the override of a parameter set with Override. If
+// the configuration without the override is invalid, the override is kept
+// and the validation error is returned.
+func (p *Parsed) ClearOverride(setName, paramName string) error {
+	if !p.beginOperation() {
+		return errStopped
+	}
+	defer p.endOperation()
+
+	defer p.saveLastKnownGood()
+
+	p.protected.valuesMutex.Lock()
+	defer p.protected.valuesMutex.Unlock()
+
+	return p.clearOverride(setName, paramName)
+}
+
+// expireOverride removes an override after its ttl expired, unless the
+// override was replaced in the meantime.
+func (p *Parsed) expireOverride(setName, paramName string, gen uint64) {
+	if !p.beginOperation() {
+		return
+	}
+	defer p.endOperation()
+
+	defer p.saveLastKnownGood()
+
+	p.protected.valuesMutex.Lock()
+	defer p.protected.valuesMutex.Unlock()
+
+	if !p.protected.overrides.isGeneration(setName, paramName, gen) {
+		return
+	}
+
+	err := p.clearOverride(setName, paramName)
+	if err == nil {
+		return
+	}
+
+	retry := min(p.protected.overrides.entries[setName][paramName].ttl, maxOverrideExpireRetryInterval)
+	p.settings.loggerFn.E(fmt.Sprintf(
+		"Override of %s.%s expired, but could not be removed, retrying in %s: %v",
+		setName, paramName, retry, err))
+
+	p.protected.overrides.expireFailed(setName, paramName, err, time.AfterFunc(retry, func() {
+		p.expireOverride(setName, paramName, gen)
+	}))
+}
+
+// clearOverride removes an override.
+// Caller must hold the mutex.
+func (p *Parsed) clearOverride(setName, paramName string) error {
+	if p.protected.overrides.get(setName, paramName) == nil {
+		return nil
+	}
+
+	p.settings.metrics.UpdateReceived(overrideProviderName)
+	previous := p.protected.overrides.values()
+
+	entry := p.protected.overrides.remove(setName, paramName)
+	current := p.protected.overrides.values()
+
+	if err := p.refresh(false); err != nil {
+		p.protected.overrides.put(setName, paramName, entry)
+		p.recordUpdate(overrideProviderName, previous, current, err)
+		return err
+	}
+
+	if entry.timer != nil {
+		entry.timer.Stop()
+	}
+
+	p.recordUpdate(overrideProviderName, previous, current, nil)
+	return nil
+}
+
+// stopOverrides stops all timers used to expire overrides.
+func (p *Parsed) stopOverrides() {
+	p.protected.valuesMutex.Lock()
+	defer p.protected.valuesMutex.Unlock()
+
+	for _, set := range p.protected.overrides.entries {
+		for _, entry := range set {
+			if entry.timer != nil {
+				entry.timer.Stop()
+			}
+		}
+	}
+}
+
+// overrides holds the values set with Parsed.Override().
+type overrides struct {
+	entries map[string]map[string]overrideEntry
+	lastGen uint64
+}
+
+type overrideEntry struct {
+	value string
+	ttl   time.Duration
+	timer *time.Timer // expires the override, nil if no ttl
+
+	// expireErr is the error produced when the override expired, but
+	// could not be removed
+	expireErr error
+
+	// gen identifies each time an override is set, allowing to determine
+	// if the timer that expires it refers to the current override.
+	gen uint64
+}
+
+// add stores an override, replacing any previous one for the same
+// parameter. Returns the generation of the new override.
+func (o *overrides) add(setName, paramName, value string) uint64 {
+	o.lastGen++
+	o.put(setName, paramName, overrideEntry{value: value, gen: o.lastGen})
+
+	return o.lastGen
+}
+
+func (o *overrides) setTimer(setName, paramName string, ttl time.Duration, timer *time.Timer) {
+	entry := o.entries[setName][paramName]
+	entry.ttl = ttl
+	entry.timer = timer
+	o.entries[setName][paramName] = entry
+}
+
+// expireFailed records that the override expired but could not be removed,
+// and the timer that retries removing it.
+func (o *overrides) expireFailed(setName, paramName string, err error, timer *time.Timer) {
+	entry := o.entries[setName][paramName]
+	entry.expireErr = err
+	entry.timer = timer
+	o.entries[setName][paramName] = entry
+}
+
+// expireError returns the error produced when the override of the parameter
+// expired, but could not be removed.
+func (o *overrides) expireError(setName, paramName string) error {
+	return o.entries[setName][paramName].expireErr
+}
+
+func (o *overrides) put(setName, paramName string, entry overrideEntry) {
+	if o.entries == nil {
+		o.entries = map[string]map[string]overrideEntry{}
+	}
+
+	set, ok := o.entries[setName]
+	if !ok {
+		set = map[string]overrideEntry{}
+		o.entries[setName] = set
+	}
+
+	set[paramName] = entry
+}
+
+func (o *overrides) remove(setName, paramName string) overrideEntry {
+	entry := o.entries[setName][paramName]
+
+	delete(o.entries[setName], paramName)
+	if len(o.entries[setName]) == 0 {
+		delete(o.entries, setName)
+	}
+
+	return entry
+}
+
+func (o *overrides) get(setName, paramName string) *string {
+	if entry, ok := o.entries[setName][paramName]; ok {
+		return &entry.value
+	}
+
+	return nil
+}
+
+func (o *overrides) isGeneration(setName, paramName string, gen uint64) bool {
+	entry, ok := o.entries[setName][paramName]
+	return ok && entry.gen == gen
+}
+
+// values returns a copy of the override values.
+func (o *overrides) values() types.ParamValues {
+	ret := make(types.ParamValues, len(o.entries))
+	for setName, set := range o.entries {
+		retSet := make(map[string]string, len(set))
+		for paramName, entry := range set {
+			retSet[paramName] = entry.value
+		}
+
+		ret[setName] = retSet
+	}
+
+	return ret
+}
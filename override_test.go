@@ -0,0 +1,173 @@
+//go:build unittest || !integrationtest
+// +build unittest !integrationtest
+
+package proteus_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/simplesurance/proteus"
+	"github.com/simplesurance/proteus/internal/assert"
+	"github.com/simplesurance/proteus/plog"
+	"github.com/simplesurance/proteus/sources/cfgtest"
+	"github.com/simplesurance/proteus/types"
+	"github.com/simplesurance/proteus/xtypes"
+)
+
+func TestOverride(t *testing.T) {
+	params := struct {
+		RateLimit *xtypes.Integer[int]
+	}{}
+
+	provider := cfgtest.New(types.ParamValues{
+		"": {"ratelimit": "100"},
+	})
+
+	parsed, err := proteus.MustParse(&params,
+		proteus.WithLogger(plog.TestLogger(t)),
+		proteus.WithProviders(provider))
+	assert.NoErrorNow(t, err)
+	defer stop(t, parsed)
+
+	assert.NoErrorNow(t, parsed.Override("", "ratelimit", "10", 0))
+	assert.Equal(t, 10, params.RateLimit.Value())
+
+	// overrides have priority over providers
+	provider.Update("", "ratelimit", ptr("200"))
+	assert.Equal(t, 10, params.RateLimit.Value())
+
+	buf := strings.Builder{}
+	parsed.Dump(&buf)
+	assert.StringContains(t, buf.String(), `- ratelimit = "10" (override)`)
+
+	for _, state := range parsed.State() {
+		if state.ParamName == "ratelimit" {
+			assert.Equal(t, "override", state.Provider)
+		}
+	}
+
+	assert.NoErrorNow(t, parsed.ClearOverride("", "ratelimit"))
+	assert.Equal(t, 200, params.RateLimit.Value())
+}
+
+func TestOverrideInvalid(t *testing.T) {
+	params := struct {
+		RateLimit *xtypes.Integer[int]
+	}{}
+
+	provider := cfgtest.New(types.ParamValues{
+		"": {"ratelimit": "100"},
+	})
+
+	parsed, err := proteus.MustParse(&params, proteus.WithProviders(provider))
+	assert.NoErrorNow(t, err)
+	defer stop(t, parsed)
+
+	assert.ErrorNow(t, parsed.Override("", "ratelimit", "invalid", 0))
+	assert.ErrorNow(t, parsed.Override("", "unknown", "1", 0))
+	assert.ErrorNow(t, parsed.Override("", "help", "true", 0))
+	assert.Equal(t, 100, params.RateLimit.Value())
+
+	for _, state := range parsed.State() {
+		if state.ParamName == "ratelimit" {
+			assert.Equal(t, "*cfgtest.TestProvider", state.Provider)
+		}
+	}
+}
+
+func TestOverrideTTL(t *testing.T) {
+	params := struct {
+		RateLimit *xtypes.Integer[int]
+	}{}
+
+	provider := cfgtest.New(types.ParamValues{
+		"": {"ratelimit": "100"},
+	})
+
+	parsed, err := proteus.MustParse(&params,
+		proteus.WithLogger(plog.TestLogger(t)),
+		proteus.WithProviders(provider))
+	assert.NoErrorNow(t, err)
+	defer stop(t, parsed)
+
+	assert.NoErrorNow(t, parsed.Override("", "ratelimit", "10", 100*time.Millisecond))
+	assert.Equal(t, 10, params.RateLimit.Value())
+
+	assert.Eventually(t, 2*time.Second, func() bool { return params.RateLimit.Value() == 100 },
+		"override must expire")
+}
+
+func TestOverrideRestrictions(t *testing.T) {
+	params := struct {
+		Token     *xtypes.String       `param:",secret"`
+		RateLimit *xtypes.Integer[int] `param:",sources=test"`
+		Level     *xtypes.Integer[int] `param:",sources=test|override"`
+	}{}
+
+	provider := cfgtest.New(types.ParamValues{
+		"": {"token": "secret", "ratelimit": "100", "level": "1"},
+	})
+
+	parsed, err := proteus.MustParse(&params,
+		proteus.WithSecretsForbiddenOn("override"),
+		proteus.WithProviders(provider))
+	assert.NoErrorNow(t, err)
+	defer stop(t, parsed)
+
+	err = parsed.Override("", "token", "other", 0)
+	assert.ErrorNow(t, err)
+	assert.StringContains(t, err.Error(), `source "override"`)
+
+	err = parsed.Override("", "ratelimit", "10", 0)
+	assert.ErrorNow(t, err)
+	assert.StringContains(t, err.Error(), "allowed sources: test")
+
+	assert.NoErrorNow(t, parsed.Override("", "level", "2", 0))
+	assert.Equal(t, "secret", params.Token.Value())
+	assert.Equal(t, 100, params.RateLimit.Value())
+	assert.Equal(t, 2, params.Level.Value())
+}
+
+// TestOverrideTTLRetry asserts that an override that can't be removed when
+// it expires is reported, and removed once the configuration without it is
+// valid.
+func TestOverrideTTLRetry(t *testing.T) {
+	params := struct {
+		RateLimit *xtypes.Integer[int]
+	}{}
+
+	provider := cfgtest.New(types.ParamValues{
+		"": {"ratelimit": "100"},
+	})
+
+	parsed, err := proteus.MustParse(&params,
+		proteus.WithLogger(func(e plog.Entry) { t.Log(e.Message) }),
+		proteus.WithProviders(provider))
+	assert.NoErrorNow(t, err)
+	defer stop(t, parsed)
+
+	// the required parameter is only provided by the override
+	assert.NoErrorNow(t, parsed.Override("", "ratelimit", "10", 50*time.Millisecond))
+	assert.NoErrorNow(t, provider.UpdateWithResult("", "ratelimit", nil))
+
+	expireError := func() error {
+		for _, state := range parsed.State() {
+			if state.ParamName == "ratelimit" {
+				return state.OverrideExpireError
+			}
+		}
+
+		return nil
+	}
+
+	assert.Eventually(t, 2*time.Second, func() bool { return expireError() != nil },
+		"override must fail to expire")
+	assert.Equal(t, 10, params.RateLimit.Value())
+
+	assert.NoErrorNow(t, provider.UpdateWithResult("", "ratelimit", ptr("200")))
+	assert.Eventually(t, 2*time.Second, func() bool { return params.RateLimit.Value() == 200 },
+		"override must expire")
+	assert.True(t, expireError() == nil, "error must be cleared")
+}
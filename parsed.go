@@ -1,6 +1,7 @@
 package proteus
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -9,6 +10,7 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/simplesurance/proteus/types"
 )
@@ -20,9 +22,53 @@ const redactedPlaceholder = "<redacted>"
 type Parsed struct {
 	settings      settings
 	inferedConfig config
+	updaters      []*updater
 	protected     struct {
 		valuesMutex sync.Mutex
 		values      []types.ParamValues
+
+		// startupValues are the values used to set up the
+		// configuration struct when the application started
+		startupValues types.ParamValues
+
+		// overrides are values set with Parsed.Override(); they
+		// have priority over values from all providers
+		overrides overrides
+
+		// reloading are the values of the providers already reloaded
+		// by a Reload in progress, by provider index; they are only
+		// visible to Peek until the reload is validated
+		reloading map[int]types.ParamValues
+
+		// unsavedLastKnownGood are the values of the cached providers
+		// applied by the last refresh, by provider index, waiting to be
+		// stored by saveLastKnownGood
+		unsavedLastKnownGood map[int]types.ParamValues
+	}
+
+	// lastKnownGoodMutex serializes writing the last known good values,
+	// that is done without holding valuesMutex
+	lastKnownGoodMutex sync.Mutex
+
+	history historyBuffer
+
+	// scrubReport is set when parsing, and not changed after that
+	scrubReport ScrubReport
+
+	lifecycle struct {
+		mutex    sync.Mutex
+		stopped  chan struct{} // closed when Stop is called
+		inFlight sync.WaitGroup
+
+		// providers whose startup timed out, by index, whose Watch
+		// may still be running
+		abandoned map[int]*watchState
+	}
+
+	reload struct {
+		mutex  sync.Mutex         // serializes calls to Reload()
+		cancel context.CancelFunc // stops handling reload signals
+		done   chan struct{}      // closed when signal handling stopped
 	}
 }
 
@@ -163,6 +209,10 @@ func (p *Parsed) help(w io.Writer) {
 				opts = append(opts, "default="+field.redactedDefaultValue())
 			}
 
+			if allowed := p.allowedSources(field); allowed != nil {
+				opts = append(opts, "sources="+strings.Join(allowed, "|"))
+			}
+
 			fmt.Fprintln(&paramDoc, strings.Join(opts, " "))
 
 			if field.desc != "" {
@@ -196,6 +246,9 @@ func (p *Parsed) Dump(w io.Writer) {
 			var paramSuffix string
 			if v := merged.Get(setName, paramName); v != nil {
 				value = *v
+				if p.protected.overrides.get(setName, paramName) != nil {
+					paramSuffix = " (override)"
+				}
 			} else {
 				value = param.redactedDefaultValue()
 				paramSuffix = " (default)"
@@ -215,14 +268,6 @@ func (p *Parsed) Valid() error {
 	return p.valid()
 }
 
-// Stop release resources being used. Proteus itself does not use any
-// resource that need to be released, but some providers might.
-func (p *Parsed) Stop() {
-	for _, p := range p.settings.providers {
-		p.Stop()
-	}
-}
-
 // validateAllXtypesDefaultValues tests if all optional parameters specified
 // using an xtype have a valid default value.
 func (p *Parsed) validateXTypeOptionalDefaults() error {
@@ -259,11 +304,25 @@ func (p *Parsed) validateXTypeOptionalDefaults() error {
 // valid determines if the desired parameters are valid.
 // Caller must hold the mutex.
 func (p *Parsed) valid() error {
-	mergedValues := p.mergeValues()
+	mergedValues := p.mergeRawValues()
 
 	violations := types.ErrViolations{}
+	if p.settings.interpolation {
+		mergedValues, violations = p.interpolate(mergedValues)
+	}
+
+	// parameters whose values can't be expanded are not validated
+	failed := map[paramID]bool{}
+	for _, v := range violations {
+		failed[paramID{v.SetName, v.ParamName}] = true
+	}
+
 	for setName, set := range p.inferedConfig {
 		for paramName, paramConfig := range set.fields {
+			if failed[paramID{setName, paramName}] {
+				continue
+			}
+
 			// must validate when a value is present and when it
 			// is missing (value=nil)
 			value := mergedValues.Get(setName, paramName)
@@ -293,12 +352,26 @@ func (p *Parsed) valid() error {
 }
 
 // mergeValues compute the configuration from all providers, taking provider
-// priority into consideration.
+// priority into consideration. References on values are expanded when
+// interpolation is enabled.
 //
 // Caller must hold the protected.mutex.
 func (p *Parsed) mergeValues() types.ParamValues {
+	ret := p.mergeRawValues()
+	if p.settings.interpolation {
+		ret, _ = p.interpolate(ret)
+	}
+
+	return ret
+}
+
+// mergeRawValues is the same as mergeValues, but never expands references.
+//
+// Caller must hold the protected.mutex.
+func (p *Parsed) mergeRawValues() types.ParamValues {
 	ret := types.ParamValues{}
-	for _, providerValues := range p.protected.values {
+	layers := append([]types.ParamValues{p.protected.overrides.values()}, p.protected.values...)
+	for _, providerValues := range layers {
 		for setName, set := range providerValues {
 			retSet, ok := ret[setName]
 			if !ok {
@@ -361,17 +434,19 @@ func (p *Parsed) validValue(setName, paramName string, value *string) error {
 }
 
 // refresh reads the available parameter values that are stored on "parsed"
-// and use them to update the configuration struct.
+// and use them to update the configuration struct. If the configuration is
+// not valid nothing is updated and the validation error is returned; the
+// caller is responsible for reporting it.
 //
 // Caller must hold the mutex.
-func (p *Parsed) refresh(force bool) {
+func (p *Parsed) refresh(force bool) error {
 	if err := p.valid(); err != nil {
-		p.settings.loggerFn.E(fmt.Sprintf(
-			"Refusing to update values because configuration is invalid: %v",
-			err.Error()))
-		return
+		return err
 	}
 
+	// references are expanded once for all parameters
+	in := p.valuesInterpolator()
+
 	for setName, set := range p.inferedConfig {
 		for paramName, paramConfig := range set.fields {
 			if !paramConfig.isXtype && !force {
@@ -382,7 +457,7 @@ func (p *Parsed) refresh(force bool) {
 				continue
 			}
 
-			value := p.desiredValue(setName, paramName)
+			value := p.desiredValue(in, setName, paramName)
 
 			if !paramConfig.isXtype && value == nil {
 				// value=nil represents the default value. For
@@ -407,26 +482,20 @@ func (p *Parsed) refresh(force bool) {
 			}
 		}
 	}
+
+	p.settings.metrics.ConfigApplied(time.Now(), p.configHash())
+	p.lastKnownGoodApplied()
+	return nil
 }
 
 // desiredValue returns the value for a parameter from one of the parameter
 // providers, respecting priority. If the value is not provider by any of them,
-// nil is returned.
+// nil is returned. References are expanded with in, that can be nil.
 // Caller must hold the mutex.
-func (p *Parsed) desiredValue(setName, paramName string) *string {
+func (p *Parsed) desiredValue(in *interpolator, setName, paramName string) *string {
 	// the first provider with a value wins
-	for _, providerData := range p.protected.values {
-		set, ok := providerData[setName]
-		if !ok {
-			continue
-		}
-
-		if value, ok := set[paramName]; ok {
-			return &value
-		}
-	}
-
-	return nil
+	value, _ := p.desiredValueAndProvider(in, setName, paramName)
+	return value
 }
 
 func binaryName() string {
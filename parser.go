@@ -1,13 +1,16 @@
 package proteus
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
 	"reflect"
 	"strings"
+	"time"
 
 	"github.com/simplesurance/proteus/internal/consts"
+	"github.com/simplesurance/proteus/metrics"
 	"github.com/simplesurance/proteus/plog"
 	"github.com/simplesurance/proteus/sources"
 	"github.com/simplesurance/proteus/sources/cfgenv"
@@ -36,9 +39,13 @@ import (
 // The value "-" for the name result in the field being ignored. The empty
 // string value indicates to infer the parameter name from the struct name. The
 // inferred parameter name is the struct name in lowercase.
-// Option can be either "secret" or "optional". An option can be provided
-// without providing the name of the parameter by using an empty value for the
-// name, resulting in the "param" tag starting with ",".
+// Option can be either "secret", "optional" or "sources=<source>[|<source>]*".
+// An option can be provided without providing the name of the parameter by
+// using an empty value for the name, resulting in the "param" tag starting
+// with ",". The "sources" option restricts from which providers the
+// parameter can be read, see sources.SourceNamer for how providers are
+// identified. For example, `param:",secret,sources=env"` only allows reading
+// the parameter from environment variables.
 //
 // The tag "param_desc" is an arbitrary string describing what the parameter
 // is for. This will be shown to the user when usage information is requested.
@@ -129,12 +136,24 @@ import (
 // A Parsed object is guaranteed to be always returned, even in case of error,
 // allowing the creation of useful error messages.
 func MustParse(config any, options ...Option) (*Parsed, error) {
+	return MustParseContext(context.Background(), config, options...)
+}
+
+// MustParseContext is the same as MustParse, but the provided context limits
+// how long proteus waits for providers to start. The context is provided to
+// providers that implement sources.ContextWatcher. To limit how long each
+// provider can take to start, use the WithProviderStartupTimeout option.
+//
+// The context is only used while parsing; canceling it after
+// MustParseContext returns has no effect.
+func MustParseContext(ctx context.Context, config any, options ...Option) (*Parsed, error) {
 	opts := settings{
 		providers: []sources.Provider{
 			cfgflags.New(),
 			cfgenv.New("CFG"),
 		},
 		loggerFn:        func(_ plog.Entry) {}, // nop logger
+		metrics:         metrics.Nop{},
 		autoUsageExitFn: func() { os.Exit(0) },
 		autoUsageWriter: os.Stdout,
 	}
@@ -152,9 +171,11 @@ func MustParse(config any, options ...Option) (*Parsed, error) {
 	ret := Parsed{
 		settings:      opts,
 		inferedConfig: appConfig,
+		updaters:      make([]*updater, len(opts.providers)),
 	}
 
 	ret.protected.values = make([]types.ParamValues, len(opts.providers))
+	ret.lifecycle.stopped = make(chan struct{})
 
 	if err := addSpecialFlags(appConfig, &ret, opts); err != nil {
 		return &ret, err
@@ -167,7 +188,13 @@ func MustParse(config any, options ...Option) (*Parsed, error) {
 	}
 
 	// start watching each configuration item on each provider
-	updaters := make([]*updater, len(opts.providers))
+	updaters := ret.updaters
+	// providers that failed to start, and are using cached values
+	type lastKnownGoodUse struct {
+		err     error
+		savedAt time.Time
+	}
+	fromLastKnownGood := map[*updater]lastKnownGoodUse{}
 	for ix, provider := range opts.providers {
 		updater := &updater{
 			parsed:         &ret,
@@ -175,33 +202,77 @@ func MustParse(config any, options ...Option) (*Parsed, error) {
 			providerName:   fmt.Sprintf("%T", provider),
 			updatesEnabled: make(chan struct{})}
 
+		if debounce := opts.debounceFor(provider); debounce.enabled() {
+			updater.debounce = newDebouncer(debounce, updater.apply)
+		}
+
+		if opts.lastKnownGood != nil && opts.lastKnownGood.caches(provider) {
+			updater.lastKnownGood = newLastKnownGood(
+				opts.lastKnownGood, ix, updater.providerName)
+		}
+
 		updaters[ix] = updater
 
-		initial, err := provider.Watch(
+		initial, err := ret.watch(ctx, provider,
 			appConfig.paramInfo(provider.IsCommandLineFlag()),
 			updater)
 		if err != nil {
-			return &ret, err
+			updater.ReportError(err)
+
+			watchErr := err
+			var savedAt time.Time
+			initial, savedAt, err = updater.loadLastKnownGood(watchErr)
+			if err != nil {
+				return &ret, err
+			}
+
+			fromLastKnownGood[updater] = lastKnownGoodUse{watchErr, savedAt}
 		}
 
 		// use the updater to store the initial values; do NOT update the
 		// "config" struct yet
-		updater.update(initial, false)
+		opts.metrics.UpdateReceived(updater.providerName)
+		if err := updater.update(initial, false); err != nil {
+			ret.recordMetrics(updater.providerName, err)
+			return &ret, err
+		}
 	}
 
 	if err := ret.valid(); err != nil {
+		ret.recordViolations(err)
 		return &ret, err
 	}
 
+	if opts.scrub != nil {
+		if err := ret.checkCommandLineSecrets(); err != nil {
+			ret.recordViolations(err)
+			return &ret, err
+		}
+	}
+
 	// send values back to the user by updating the fields on the
-	// "config" parameter
-	ret.refresh(true)
+	// "config" parameter; values were already validated
+	_ = ret.refresh(true)
+	ret.protected.startupValues = ret.mergeValues()
+	ret.saveLastKnownGood()
+
+	if opts.scrub != nil {
+		ret.scrubSecrets()
+	}
 
 	// allow all sources to provide updates
 	for _, updater := range updaters {
+		opts.metrics.UpdateApplied(updater.providerName)
+		updater.updateAccepted()
+		if use, ok := fromLastKnownGood[updater]; ok {
+			updater.startedFromLastKnownGood(use.err, use.savedAt)
+		}
+
 		close(updater.updatesEnabled)
 	}
 
+	ret.startReloadOnSignal()
+
 	return &ret, nil
 }
 
@@ -355,14 +426,21 @@ func parseParam(structField reflect.StructField, fieldVal reflect.Value) (
 	}
 
 	for _, tagOption := range tagParamParts[1:] {
-		switch tagOption {
-		case "optional":
+		switch {
+		case tagOption == "optional":
 			ret.optional = true
-		case "secret":
+		case tagOption == "secret":
 			ret.secret = true
+		case strings.HasPrefix(tagOption, sourcesTagOption):
+			sourceNames, err := parseSourcesTagOption(tagOption)
+			if err != nil {
+				return paramName, ret, fmt.Errorf("%w in '%s'", err, tagParam)
+			}
+
+			ret.sources = sourceNames
 		default:
 			return paramName, ret, fmt.Errorf(
-				"option '%s' is invalid for tag 'param' in '%s'; valid options are optional|secret",
+				"option '%s' is invalid for tag 'param' in '%s'; valid options are optional|secret|sources=<source>[|<source>]*",
 				tagOption,
 				tagParam)
 		}
@@ -379,6 +457,7 @@ func parseParam(structField reflect.StructField, fieldVal reflect.Value) (
 	err := configStandardCallbacks(&ret, fieldVal)
 	if err == nil {
 		ret.typ = describeType(fieldVal)
+		ret.valueFn = fieldVal.Interface
 		return paramName, ret, nil
 	}
 
@@ -402,6 +481,16 @@ func parseParam(structField reflect.StructField, fieldVal reflect.Value) (
 		ret.setValueFn = toXType(fieldVal).UnmarshalParam
 		ret.getDefaultFn = toXType(fieldVal).GetDefaultValue
 
+		if isSecretXType(fieldVal) {
+			ret.secret = true
+		}
+
+		if valueMethod := fieldVal.MethodByName("Value"); valueMethod.IsValid() {
+			ret.valueFn = func() any {
+				return valueMethod.Call(nil)[0].Interface()
+			}
+		}
+
 		// some types know how to redact themselves (for example,
 		// xtype.URL know how to redact the password)
 		if redactor := toRedactor(fieldVal); redactor != nil {
@@ -25,6 +25,16 @@ func (logger Logger) I(msg string, opts ...Option) {
 	})
 }
 
+// W creates a log entry with severity "warning".
+func (logger Logger) W(msg string, opts ...Option) {
+	o := applyOptions(opts...)
+	logger(Entry{
+		Severity: SevWarning,
+		Message:  msg,
+		Caller:   ReadCaller(o.skipCallers),
+	})
+}
+
 // E creates a log entry with severity "error".
 func (logger Logger) E(msg string, opts ...Option) {
 	o := applyOptions(opts...)
@@ -7,9 +7,10 @@ import (
 
 var (
 	severityStrings = map[Severity]string{
-		SevDebug: "debug",
-		SevInfo:  "info",
-		SevError: "error",
+		SevDebug:   "debug",
+		SevInfo:    "info",
+		SevError:   "error",
+		SevWarning: "warning",
 	}
 )
 
@@ -47,7 +48,8 @@ func (s *Severity) UnmarshalJSON(b []byte) error {
 }
 
 const (
-	SevInfo  = 0
-	SevDebug = 1
-	SevError = 2
+	SevInfo    = 0
+	SevDebug   = 1
+	SevError   = 2
+	SevWarning = 3
 )
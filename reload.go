@@ -0,0 +1,201 @@
+package proteus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"os/signal"
+	"slices"
+
+	"github.com/simplesurance/proteus/sources"
+	"github.com/simplesurance/proteus/types"
+)
+
+// Reload requests all providers that implement sources.Reloader to read their
+// configuration source again. After all providers are reloaded the
+// configuration is validated and, if valid, xtypes are updated with the new
+// values. Providers that do not implement sources.Reloader keep their
+// current values.
+//
+// The returned error aggregates the errors reported by providers while
+// reloading and the result of validating the resulting configuration. When
+// the configuration is invalid, the returned error contains
+// types.ErrViolations, and the configuration struct is not updated.
+func (p *Parsed) Reload(ctx context.Context) error {
+	if !p.beginOperation() {
+		return errStopped
+	}
+	defer p.endOperation()
+
+	p.reload.mutex.Lock()
+	defer p.reload.mutex.Unlock()
+
+	// values are only stored after all providers reload, so concurrent
+	// updates never see a partially reloaded configuration; meanwhile,
+	// they are made visible to Peek, so providers configured by the ones
+	// before them reload with their new values
+	var errs []error
+	reloaded := map[int]types.ParamValues{}
+	defer p.setReloading(nil)
+	for ix, provider := range p.settings.providers {
+		reloader, ok := provider.(sources.Reloader)
+		if !ok {
+			continue
+		}
+
+		updater := p.updaters[ix]
+		if updater == nil {
+			// provider was not successfully initialized
+			continue
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		p.settings.metrics.UpdateReceived(updater.providerName)
+		values, err := reloader.Reload(ctx)
+		if err != nil {
+			updater.ReportError(err)
+			p.recordMetrics(updater.providerName, err)
+			errs = append(errs, fmt.Errorf("reloading %s: %w", updater.providerName, err))
+			continue
+		}
+
+		values, err = updater.prepare(values)
+		if err != nil {
+			updater.updateRejected(err)
+			p.recordMetrics(updater.providerName, err)
+			errs = append(errs, fmt.Errorf("reloading %s: %w", updater.providerName, err))
+			continue
+		}
+
+		reloaded[ix] = values
+		p.setReloading(reloaded)
+	}
+
+	// runs after the mutex is released
+	defer p.saveLastKnownGood()
+
+	p.protected.valuesMutex.Lock()
+	defer p.protected.valuesMutex.Unlock()
+
+	previous := map[int]types.ParamValues{}
+	for ix, values := range reloaded {
+		previous[ix] = p.protected.values[ix]
+		p.protected.values[ix] = values
+	}
+
+	err := p.refresh(false)
+	for ix := range p.updaters {
+		values, ok := previous[ix]
+		if !ok {
+			continue
+		}
+
+		p.recordUpdate(p.updaters[ix].providerName, values, p.protected.values[ix], err)
+
+		if err != nil {
+			// reject the reloaded values
+			p.protected.values[ix] = values
+			p.updaters[ix].updateRejected(err)
+		} else {
+			p.updaters[ix].updateAccepted()
+		}
+	}
+
+	p.protected.reloading = nil
+	if err != nil {
+		errs = append(errs, err)
+
+		// providers after the reloaded ones may have read the rejected
+		// values with Peek
+		if first, ok := firstIndex(reloaded); ok {
+			p.notifyUpstreamChanged(first)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// setReloading makes the values of the providers reloaded so far visible to
+// Peek.
+func (p *Parsed) setReloading(values map[int]types.ParamValues) {
+	p.protected.valuesMutex.Lock()
+	defer p.protected.valuesMutex.Unlock()
+
+	p.protected.reloading = maps.Clone(values)
+}
+
+// firstIndex returns the lowest provider index with values.
+func firstIndex(values map[int]types.ParamValues) (int, bool) {
+	if len(values) == 0 {
+		return 0, false
+	}
+
+	return slices.Min(slices.Collect(maps.Keys(values))), true
+}
+
+// providerValues returns the values currently stored for the provider at
+// index ix.
+func (p *Parsed) providerValues(ix int) types.ParamValues {
+	p.protected.valuesMutex.Lock()
+	defer p.protected.valuesMutex.Unlock()
+
+	return p.protected.values[ix]
+}
+
+// startReloadOnSignal starts handling the signals configured with the
+// WithReloadOnSignal option, if any.
+func (p *Parsed) startReloadOnSignal() {
+	if len(p.settings.reloadSignals) == 0 {
+		return
+	}
+
+	// cancelled by Stop, also interrupting reloads in progress
+	ctx, cancel := context.WithCancel(context.Background())
+	p.reload.cancel = cancel
+	p.reload.done = make(chan struct{})
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, p.settings.reloadSignals...)
+
+	go func() {
+		defer close(p.reload.done)
+		defer signal.Stop(sigCh)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case sig := <-sigCh:
+				p.settings.loggerFn.I(fmt.Sprintf(
+					"Received signal %q, reloading configuration", sig))
+
+				if err := p.Reload(ctx); err != nil {
+					p.settings.loggerFn.E(fmt.Sprintf(
+						"Reloading configuration after signal %q: %v", sig, err))
+				}
+			}
+		}
+	}()
+}
+
+// stopReloadOnSignal stops handling reload signals and waits until the
+// signal handler terminates, or until the context is done.
+func (p *Parsed) stopReloadOnSignal(ctx context.Context) error {
+	if p.reload.cancel == nil {
+		return nil
+	}
+
+	p.reload.cancel()
+
+	select {
+	case <-p.reload.done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("waiting for the reload signal handler: %w", ctx.Err())
+	}
+}
@@ -0,0 +1,218 @@
+//go:build unittest || !integrationtest
+// +build unittest !integrationtest
+
+package proteus_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"runtime"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/simplesurance/proteus"
+	"github.com/simplesurance/proteus/internal/assert"
+	"github.com/simplesurance/proteus/plog"
+	"github.com/simplesurance/proteus/sources"
+	"github.com/simplesurance/proteus/sources/cfgenv"
+	"github.com/simplesurance/proteus/sources/cfgtest"
+	"github.com/simplesurance/proteus/types"
+	"github.com/simplesurance/proteus/xtypes"
+)
+
+func TestReload(t *testing.T) {
+	t.Setenv("RELOADTEST__NAME", "initial")
+	t.Setenv("RELOADTEST__LEVEL", "1")
+
+	params := struct {
+		Name  *xtypes.String
+		Level *xtypes.Integer[int]
+	}{}
+
+	parsed, err := proteus.MustParse(&params,
+		proteus.WithLogger(plog.TestLogger(t)),
+		proteus.WithProviders(cfgenv.New("RELOADTEST")))
+	assert.NoErrorNow(t, err)
+	defer stop(t, parsed)
+
+	assert.Equal(t, "initial", params.Name.Value())
+	assert.Equal(t, 1, params.Level.Value())
+
+	t.Setenv("RELOADTEST__NAME", "reloaded")
+	t.Setenv("RELOADTEST__LEVEL", "2")
+
+	err = parsed.Reload(context.Background())
+	assert.NoErrorNow(t, err)
+
+	assert.Equal(t, "reloaded", params.Name.Value())
+	assert.Equal(t, 2, params.Level.Value())
+}
+
+func TestReloadInvalid(t *testing.T) {
+	t.Setenv("RELOADTEST__LEVEL", "1")
+
+	params := struct {
+		Level *xtypes.Integer[int]
+	}{}
+
+	parsed, err := proteus.MustParse(&params,
+		proteus.WithProviders(cfgenv.New("RELOADTEST")))
+	assert.NoErrorNow(t, err)
+	defer stop(t, parsed)
+
+	t.Setenv("RELOADTEST__LEVEL", "not a number")
+
+	err = parsed.Reload(context.Background())
+	assert.ErrorNow(t, err)
+
+	var violations types.ErrViolations
+	assert.TrueNow(t, errors.As(err, &violations), "error must contain violations")
+	assert.Equal(t, 1, len(violations))
+	assert.Equal(t, "level", violations[0].ParamName)
+
+	// invalid configuration must not be applied
+	assert.Equal(t, 1, params.Level.Value())
+}
+
+func TestReloadOnSignal(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("sending signals is not supported on windows")
+	}
+
+	t.Setenv("RELOADTEST__NAME", "initial")
+
+	params := struct {
+		Name *xtypes.String
+	}{}
+
+	parsed, err := proteus.MustParse(&params,
+		proteus.WithLogger(plog.TestLogger(t)),
+		proteus.WithReloadOnSignal(syscall.SIGHUP),
+		proteus.WithProviders(cfgenv.New("RELOADTEST")))
+	assert.NoErrorNow(t, err)
+	defer stop(t, parsed)
+
+	t.Setenv("RELOADTEST__NAME", "reloaded")
+
+	proc, err := os.FindProcess(os.Getpid())
+	assert.NoErrorNow(t, err)
+	assert.NoErrorNow(t, proc.Signal(syscall.SIGHUP))
+
+	assert.Eventually(t, 2*time.Second, func() bool { return params.Name.Value() == "reloaded" },
+		"configuration must be reloaded")
+}
+
+// TestReloadConcurrentUpdate asserts that updates received while providers
+// are reloading are not validated against partially reloaded values.
+func TestReloadConcurrentUpdate(t *testing.T) {
+	t.Setenv("RELOADTEST__LEVEL", "1")
+
+	params := struct {
+		Level *xtypes.Integer[int]
+		Name  *xtypes.String `param:",optional"`
+	}{}
+
+	env := cfgenv.New("RELOADTEST")
+	gated := newGatedReloader(types.ParamValues{})
+	provider := cfgtest.New(types.ParamValues{"": {"name": "initial"}})
+
+	parsed, err := proteus.MustParse(&params,
+		proteus.WithProviders(env, gated, provider))
+	assert.NoErrorNow(t, err)
+	defer stop(t, parsed)
+
+	// the first provider reloads an invalid value, then the reload waits
+	// for the second provider
+	t.Setenv("RELOADTEST__LEVEL", "not a number")
+
+	reloadErr := make(chan error, 1)
+	go func() {
+		reloadErr <- parsed.Reload(context.Background())
+	}()
+
+	<-gated.reloading
+	assert.NoErrorNow(t, provider.UpdateWithResult("", "name", ptr("updated")))
+	assert.Equal(t, "updated", params.Name.Value())
+	assert.Equal(t, 1, params.Level.Value())
+
+	close(gated.release)
+	assert.ErrorNow(t, <-reloadErr)
+	assert.Equal(t, 1, params.Level.Value())
+}
+
+// TestStopInterruptsReloadOnSignal asserts that Stop cancels reloads
+// started by signals.
+func TestStopInterruptsReloadOnSignal(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("sending signals is not supported on windows")
+	}
+
+	params := struct {
+		Name string `param:",optional"`
+	}{}
+
+	provider := newGatedReloader(nil)
+	parsed, err := proteus.MustParse(&params,
+		proteus.WithReloadOnSignal(syscall.SIGHUP),
+		proteus.WithProviders(provider))
+	assert.NoErrorNow(t, err)
+
+	proc, err := os.FindProcess(os.Getpid())
+	assert.NoErrorNow(t, err)
+	assert.NoErrorNow(t, proc.Signal(syscall.SIGHUP))
+
+	select {
+	case <-provider.reloading:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("timeout waiting for the configuration to be reloaded")
+	}
+
+	start := time.Now()
+	stop(t, parsed)
+	assert.True(t, time.Since(start) < 2*time.Second, "Stop must interrupt the reload")
+}
+
+// gatedReloader is a provider whose Reload blocks until release is closed
+// or its context is done, then returns values.
+type gatedReloader struct {
+	values    types.ParamValues
+	reloading chan struct{}
+	release   chan struct{}
+}
+
+var _ sources.Reloader = &gatedReloader{}
+
+func newGatedReloader(values types.ParamValues) *gatedReloader {
+	return &gatedReloader{
+		values:    values,
+		reloading: make(chan struct{}),
+		release:   make(chan struct{}),
+	}
+}
+
+func (p *gatedReloader) IsCommandLineFlag() bool {
+	return false
+}
+
+func (p *gatedReloader) Stop() {
+}
+
+func (p *gatedReloader) Watch(
+	sources.Parameters,
+	sources.Updater,
+) (types.ParamValues, error) {
+	return types.ParamValues{}, nil
+}
+
+func (p *gatedReloader) Reload(ctx context.Context) (types.ParamValues, error) {
+	close(p.reloading)
+
+	select {
+	case <-p.release:
+		return p.values, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,115 @@
+package proteus
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/simplesurance/proteus/sources"
+	"github.com/simplesurance/proteus/types"
+)
+
+const sourcesTagOption = "sources="
+
+// parseSourcesTagOption parses the "sources" option of the "param" tag,
+// returning the names of the allowed sources.
+func parseSourcesTagOption(opt string) ([]string, error) {
+	value := strings.TrimPrefix(opt, sourcesTagOption)
+	if value == "" {
+		return nil, errors.New("option 'sources' requires at least one source")
+	}
+
+	var ret []string
+	for _, name := range strings.Split(value, "|") {
+		if name == "" {
+			return nil, fmt.Errorf("option '%s' has an empty source name", opt)
+		}
+
+		ret = append(ret, sources.NormalizeSourceName(name))
+	}
+
+	return ret, nil
+}
+
+// sourceAllowed returns true if the parameter can be read from the source.
+func (p *Parsed) sourceAllowed(field paramSetField, source string) bool {
+	if field.secret && slices.Contains(p.settings.secretsForbiddenOn, source) {
+		return false
+	}
+
+	return field.sources == nil || slices.Contains(field.sources, source)
+}
+
+// allowedSources returns the names of the sources from where the parameter
+// can be read, or nil if there is no restriction.
+func (p *Parsed) allowedSources(field paramSetField) []string {
+	forbidden := field.secret && len(p.settings.secretsForbiddenOn) > 0
+	if field.sources == nil && !forbidden {
+		return nil
+	}
+
+	candidates := field.sources
+	if candidates == nil {
+		for _, provider := range p.settings.providers {
+			if name := sources.SourceName(provider); !slices.Contains(candidates, name) {
+				candidates = append(candidates, name)
+			}
+		}
+	}
+
+	ret := []string{}
+	for _, name := range candidates {
+		if p.sourceAllowed(field, name) {
+			ret = append(ret, name)
+		}
+	}
+
+	return ret
+}
+
+// sourceViolation returns the violation for a value provided for a parameter
+// by a source from where it can't be read.
+func (p *Parsed) sourceViolation(
+	setName, paramName string,
+	field paramSetField,
+	providerName, sourceName string,
+) types.Violation {
+	allowed := "none"
+	if names := p.allowedSources(field); len(names) > 0 {
+		allowed = strings.Join(names, "|")
+	}
+
+	return types.Violation{
+		SetName:   setName,
+		ParamName: paramName,
+		Message: fmt.Sprintf(
+			"value is not allowed from %s (source %q); allowed sources: %s",
+			providerName, sourceName, allowed),
+	}
+}
+
+// checkSources verifies that the provider is allowed to provide values for
+// all parameters in v.
+func (u *updater) checkSources(v types.ParamValues) error {
+	sourceName := u.sourceName()
+
+	var violations types.ErrViolations
+	for setName, set := range v {
+		for paramName := range set {
+			field, ok := u.parsed.inferedConfig.getParam(setName, paramName)
+			if !ok || u.parsed.sourceAllowed(field, sourceName) {
+				continue
+			}
+
+			violations = append(violations, u.parsed.sourceViolation(
+				setName, paramName, field, u.providerName, sourceName))
+		}
+	}
+
+	if len(violations) == 0 {
+		return nil
+	}
+
+	return violations
+}
@@ -0,0 +1,95 @@
+//go:build unittest || !integrationtest
+// +build unittest !integrationtest
+
+package proteus_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/simplesurance/proteus"
+	"github.com/simplesurance/proteus/internal/assert"
+	"github.com/simplesurance/proteus/sources/cfgenv"
+	"github.com/simplesurance/proteus/sources/cfgtest"
+	"github.com/simplesurance/proteus/types"
+)
+
+func TestSourcesTagOption(t *testing.T) {
+	params := struct {
+		Password string `param:",secret,sources=env"`
+		Name     string
+	}{}
+
+	t.Setenv("RESTRICTTEST__PASSWORD", "from env")
+
+	parsed, err := proteus.MustParse(&params, proteus.WithProviders(
+		cfgtest.New(types.ParamValues{"": {"name": "from test"}}),
+		cfgenv.New("RESTRICTTEST")))
+	assert.NoErrorNow(t, err)
+	defer stop(t, parsed)
+
+	assert.Equal(t, "from env", params.Password)
+	assert.Equal(t, "from test", params.Name)
+
+	usage := bytes.Buffer{}
+	parsed.Usage(&usage)
+	assert.StringContains(t, usage.String(), "- password secret sources=env\n")
+
+	// the test provider is not allowed to provide the password
+	parsed, err = proteus.MustParse(&params, proteus.WithProviders(
+		cfgtest.New(types.ParamValues{"": {"name": "from test", "password": "x"}}),
+		cfgenv.New("RESTRICTTEST")))
+	assert.ErrorNow(t, err)
+	defer stop(t, parsed)
+
+	var violations types.ErrViolations
+	assert.TrueNow(t, errors.As(err, &violations), "error must be violations")
+	assert.EqualNow(t, 1, len(violations))
+	assert.Equal(t, "password", violations[0].ParamName)
+	assert.StringContains(t, violations[0].Message, "*cfgtest.TestProvider")
+	assert.StringContains(t, violations[0].Message, "allowed sources: env")
+}
+
+func TestSecretsForbiddenOn(t *testing.T) {
+	params := struct {
+		Password string `param:",secret,optional"`
+		Name     string
+	}{}
+
+	t.Setenv("RESTRICTTEST__PASSWORD", "from env")
+
+	provider := cfgtest.New(types.ParamValues{"": {"name": "from test"}})
+
+	parsed, err := proteus.MustParse(&params,
+		proteus.WithSecretsForbiddenOn("cfgtest"),
+		proteus.WithProviders(provider, cfgenv.New("RESTRICTTEST")))
+	assert.NoErrorNow(t, err)
+	defer stop(t, parsed)
+
+	assert.Equal(t, "from env", params.Password)
+
+	usage := bytes.Buffer{}
+	parsed.Usage(&usage)
+	assert.StringContains(t, usage.String(), "sources=env\n")
+
+	// updates are also verified
+	err = provider.UpdateWithResult("", "password", ptr("from test"))
+	assert.ErrorNow(t, err)
+	assert.StringContains(t, err.Error(), `source "test"`)
+
+	// non-secret parameters are not affected
+	assert.NoErrorNow(t, provider.UpdateWithResult("", "password", nil))
+	assert.NoErrorNow(t, provider.UpdateWithResult("", "name", ptr("updated")))
+}
+
+func TestSourcesTagOptionInvalid(t *testing.T) {
+	params := struct {
+		Password string `param:",sources="`
+	}{}
+
+	assert.PanicsNow(t, func() {
+		_, _ = proteus.MustParse(&params, proteus.WithProviders(
+			cfgtest.New(types.ParamValues{})))
+	})
+}
@@ -0,0 +1,168 @@
+package proteus
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+
+	"github.com/simplesurance/proteus/internal/paramvalues"
+	"github.com/simplesurance/proteus/sources"
+	"github.com/simplesurance/proteus/types"
+)
+
+// ScrubOptions specifies how secrets are scrubbed. See WithSecretScrubbing.
+type ScrubOptions struct {
+	// FailOnCommandLineSecrets makes MustParse fail when a secret is
+	// provided as a command-line flag. By default, only a warning is
+	// logged.
+	FailOnCommandLineSecrets bool
+}
+
+// ScrubReport describes what was done to scrub secrets after the
+// configuration was parsed. See WithSecretScrubbing.
+type ScrubReport struct {
+	// Scrubbed are the secrets that were removed from their sources.
+	Scrubbed []SecretLocation
+
+	// CommandLine are the secrets provided as command-line flags, which
+	// can't be scrubbed.
+	CommandLine []SecretLocation
+}
+
+// SecretLocation describes from where the value of a secret was read.
+type SecretLocation struct {
+	SetName   string
+	ParamName string
+
+	// Provider is the name of the provider that provided the value.
+	Provider string
+
+	// Location describes where the value was stored, like the name of an
+	// environment variable. It is empty when the provider can't tell it.
+	Location string
+}
+
+// ScrubReport returns what was done to scrub secrets after the configuration
+// was parsed. The report is empty if WithSecretScrubbing was not used.
+func (p *Parsed) ScrubReport() ScrubReport {
+	return ScrubReport{
+		Scrubbed:    append([]SecretLocation(nil), p.scrubReport.Scrubbed...),
+		CommandLine: append([]SecretLocation(nil), p.scrubReport.CommandLine...),
+	}
+}
+
+// checkCommandLineSecrets reports secrets that were provided as
+// command-line flags. When configured to fail, they are returned as
+// violations.
+func (p *Parsed) checkCommandLineSecrets() error {
+	var violations types.ErrViolations
+	for ix, provider := range p.settings.providers {
+		if !provider.IsCommandLineFlag() {
+			continue
+		}
+
+		u := p.updaters[ix]
+		for setName, set := range p.secretValues(ix) {
+			for paramName := range set {
+				p.scrubReport.CommandLine = append(p.scrubReport.CommandLine, SecretLocation{
+					SetName:   setName,
+					ParamName: paramName,
+					Provider:  u.providerName,
+				})
+
+				msg := fmt.Sprintf(
+					"secret provided by %s, it can be seen by other users of the system",
+					u.providerName)
+
+				if !p.settings.scrub.FailOnCommandLineSecrets {
+					p.settings.loggerFn.E(fmt.Sprintf("Parameter %s.%s: %s",
+						setName, paramName, msg))
+					continue
+				}
+
+				violations = append(violations, types.Violation{
+					SetName:   setName,
+					ParamName: paramName,
+					Message:   msg,
+				})
+			}
+		}
+	}
+
+	sortSecretLocations(p.scrubReport.CommandLine)
+
+	if len(violations) == 0 {
+		return nil
+	}
+
+	return violations
+}
+
+// scrubSecrets asks providers that implement sources.Scrubber to remove the
+// secrets they provided from their sources. Errors are logged.
+func (p *Parsed) scrubSecrets() {
+	for ix, provider := range p.settings.providers {
+		scrubber, ok := provider.(sources.Scrubber)
+		if !ok {
+			continue
+		}
+
+		secrets := p.secretValues(ix)
+		if len(secrets) == 0 {
+			continue
+		}
+
+		paramIDs := sources.Parameters{}
+		for setName, set := range secrets {
+			paramIDs[setName] = map[string]sources.ParameterInfo{}
+			for paramName := range set {
+				paramIDs[setName][paramName] = sources.ParameterInfo{}
+			}
+		}
+
+		u := p.updaters[ix]
+		scrubbed, err := scrubber.Scrub(paramIDs)
+		if err != nil {
+			p.settings.loggerFn.E(fmt.Sprintf(
+				"Scrubbing secrets provided by %s: %v", u.providerName, err))
+		}
+
+		for _, s := range scrubbed {
+			p.settings.loggerFn.D(fmt.Sprintf(
+				"Scrubbed secret %s.%s from %s", s.SetName, s.ParamName, s.Location))
+
+			p.scrubReport.Scrubbed = append(p.scrubReport.Scrubbed, SecretLocation{
+				SetName:   s.SetName,
+				ParamName: s.ParamName,
+				Provider:  u.providerName,
+				Location:  s.Location,
+			})
+		}
+	}
+
+	sortSecretLocations(p.scrubReport.Scrubbed)
+}
+
+func sortSecretLocations(l []SecretLocation) {
+	slices.SortFunc(l, func(a, b SecretLocation) int {
+		return cmp.Or(
+			cmp.Compare(a.SetName, b.SetName),
+			cmp.Compare(a.ParamName, b.ParamName),
+			cmp.Compare(a.Provider, b.Provider))
+	})
+}
+
+// secretValues returns the values of secret parameters provided by the
+// provider at index ix.
+func (p *Parsed) secretValues(ix int) types.ParamValues {
+	ret := types.ParamValues{}
+	for setName, set := range p.providerValues(ix) {
+		for paramName, value := range set {
+			if param, ok := p.inferedConfig.getParam(setName, paramName); ok && param.secret {
+				paramvalues.Set(ret, setName, paramName, value)
+			}
+		}
+	}
+
+	return ret
+}
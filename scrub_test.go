@@ -0,0 +1,83 @@
+//go:build unittest || !integrationtest
+// +build unittest !integrationtest
+
+package proteus_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/simplesurance/proteus"
+	"github.com/simplesurance/proteus/internal/assert"
+	"github.com/simplesurance/proteus/sources/cfgenv"
+	"github.com/simplesurance/proteus/sources/cfgtest"
+	"github.com/simplesurance/proteus/types"
+)
+
+func TestSecretScrubbing(t *testing.T) {
+	t.Setenv("SCRUBTEST__NAME", "name")
+	t.Setenv("SCRUBTEST__TOKEN", "my-token")
+
+	params := struct {
+		Name  string
+		Token string `param:",secret"`
+	}{}
+
+	parsed, err := proteus.MustParse(&params,
+		proteus.WithSecretScrubbing(proteus.ScrubOptions{}),
+		proteus.WithProviders(cfgenv.New("SCRUBTEST")))
+	assert.NoErrorNow(t, err)
+	defer stop(t, parsed)
+
+	assert.Equal(t, "my-token", params.Token)
+
+	_, ok := os.LookupEnv("SCRUBTEST__TOKEN")
+	assert.True(t, !ok, "secret must be removed from the environment")
+	assert.Equal(t, "name", os.Getenv("SCRUBTEST__NAME"))
+
+	report := parsed.ScrubReport()
+	assert.EqualNow(t, 1, len(report.Scrubbed))
+	assert.Equal(t, proteus.SecretLocation{
+		ParamName: "token",
+		Provider:  "*cfgenv.envVarProvider",
+		Location:  "SCRUBTEST__TOKEN",
+	}, report.Scrubbed[0])
+	assert.Equal(t, 0, len(report.CommandLine))
+
+	// scrubbed values are still provided when reloading
+	assert.NoErrorNow(t, parsed.Reload(context.Background()))
+}
+
+func TestSecretScrubbingCommandLine(t *testing.T) {
+	params := struct {
+		Token string `param:",secret"`
+	}{}
+
+	// the test provider is handled like command-line flags
+	newProvider := func() *cfgtest.TestProvider {
+		return cfgtest.New(types.ParamValues{"": {"token": "my-token"}})
+	}
+
+	parsed, err := proteus.MustParse(&params,
+		proteus.WithSecretScrubbing(proteus.ScrubOptions{}),
+		proteus.WithProviders(newProvider()))
+	assert.NoErrorNow(t, err)
+	defer stop(t, parsed)
+
+	report := parsed.ScrubReport()
+	assert.Equal(t, 0, len(report.Scrubbed))
+	assert.EqualNow(t, 1, len(report.CommandLine))
+	assert.Equal(t, "token", report.CommandLine[0].ParamName)
+
+	parsed, err = proteus.MustParse(&params,
+		proteus.WithSecretScrubbing(proteus.ScrubOptions{FailOnCommandLineSecrets: true}),
+		proteus.WithProviders(newProvider()))
+	assert.ErrorNow(t, err)
+	defer stop(t, parsed)
+
+	var violations types.ErrViolations
+	assert.TrueNow(t, errors.As(err, &violations), "error must be violations")
+	assert.Equal(t, "token", violations[0].ParamName)
+}
@@ -0,0 +1,346 @@
+// Package cfgchain is a parameter provider that allows configuring another
+// provider with the value of a parameter, read from the providers
+// registered before it. For example, it allows the path of a configuration
+// file to be provided as a command-line flag:
+//
+//	params := struct {
+//		ConfigFile *xtypes.String `param:"config-file,optional"`
+//		// ...
+//	}{}
+//
+//	parsed, err := proteus.MustParse(&params, proteus.WithProviders(
+//		cfgflags.New(),
+//		cfgenv.New("CFG"),
+//		cfgchain.New("", "config-file", func(path string) (sources.Provider, error) {
+//			return cfgfile.New(path), nil
+//		}),
+//	))
+//
+// The parameter is declared on the configuration struct like any other,
+// and is read with sources.Updater.Peek. When its value changes, a new
+// provider is created for the new value, replacing the previous one. If
+// the parameter is not provided, no provider is created, and no values are
+// provided.
+//
+// Values are identified by the source name of the created provider, so
+// restrictions like the "sources" option of the "param" tag apply to it, as
+// if it was registered directly.
+package cfgchain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/simplesurance/proteus/internal/paramvalues"
+	"github.com/simplesurance/proteus/plog"
+	"github.com/simplesurance/proteus/sources"
+	"github.com/simplesurance/proteus/types"
+)
+
+// Factory creates the provider configured by the value of the parameter.
+type Factory func(value string) (sources.Provider, error)
+
+// New creates a provider that reads the value of a parameter from the
+// providers registered before it, and uses the provider created with it by
+// factory. See package description for details.
+func New(setName, paramName string, factory Factory) sources.Provider {
+	ret := &chainProvider{
+		setName:   setName,
+		paramName: paramName,
+		factory:   factory,
+		changed:   make(chan struct{}, 1),
+		done:      make(chan struct{}),
+	}
+
+	ret.ctx, ret.cancel = context.WithCancel(context.Background())
+
+	return ret
+}
+
+type chainProvider struct {
+	setName   string
+	paramName string
+	factory   Factory
+
+	paramIDs sources.Parameters
+	updater  sources.Updater
+
+	// mutex protects the current provider, and serializes replacing it
+	mutex    sync.Mutex
+	value    *string
+	provider sources.Provider
+
+	// current identifies the current provider, and its last values; it
+	// has its own mutex, so replaced providers can be stopped while they
+	// are sending updates
+	current struct {
+		mutex      sync.Mutex
+		generation int
+		values     types.ParamValues
+		sourceName string
+	}
+
+	changed chan struct{}
+	ctx     context.Context
+	cancel  context.CancelFunc
+	started bool
+	done    chan struct{}
+}
+
+var (
+	_ sources.ContextWatcher   = &chainProvider{}
+	_ sources.ContextStopper   = &chainProvider{}
+	_ sources.Reloader         = &chainProvider{}
+	_ sources.UpstreamObserver = &chainProvider{}
+	_ sources.SourceNamer      = &chainProvider{}
+)
+
+func (r *chainProvider) IsCommandLineFlag() bool {
+	return false
+}
+
+func (r *chainProvider) Stop() {
+	_ = r.StopContext(context.Background())
+}
+
+// SourceName identifies the current provider, so restrictions on from where
+// parameters can be read apply to the provider created by the factory.
+func (r *chainProvider) SourceName() string {
+	r.current.mutex.Lock()
+	defer r.current.mutex.Unlock()
+
+	if r.current.sourceName == "" {
+		return "chain"
+	}
+
+	return r.current.sourceName
+}
+
+// StopContext stops the provider created by the factory.
+func (r *chainProvider) StopContext(ctx context.Context) error {
+	r.cancel()
+
+	r.mutex.Lock()
+	started := r.started
+	r.mutex.Unlock()
+
+	if started {
+		select {
+		case <-r.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return stop(ctx, r.provider)
+}
+
+func (r *chainProvider) Watch(
+	paramIDs sources.Parameters,
+	updater sources.Updater,
+) (initial types.ParamValues, _ error) {
+	return r.WatchContext(context.Background(), paramIDs, updater)
+}
+
+// WatchContext starts the provider configured by the parameter, passing
+// ctx to it if it implements sources.ContextWatcher.
+func (r *chainProvider) WatchContext(
+	ctx context.Context,
+	paramIDs sources.Parameters,
+	updater sources.Updater,
+) (initial types.ParamValues, _ error) {
+	r.paramIDs = paramIDs
+	r.updater = updater
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	initial, _, err := r.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	r.started = true
+	go r.watchUpstream()
+
+	return initial, nil
+}
+
+// UpstreamChanged requests the value of the parameter to be read again.
+func (r *chainProvider) UpstreamChanged() {
+	select {
+	case r.changed <- struct{}{}:
+	default:
+		// already requested
+	}
+}
+
+// Reload reads the value of the parameter again. If it did not change, the
+// current provider is reloaded, if it implements sources.Reloader.
+func (r *chainProvider) Reload(ctx context.Context) (types.ParamValues, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	values, replaced, err := r.resolve(ctx)
+	if err != nil || replaced {
+		return values, err
+	}
+
+	reloader, ok := r.provider.(sources.Reloader)
+	if !ok {
+		return values, nil
+	}
+
+	return reloader.Reload(ctx)
+}
+
+func (r *chainProvider) watchUpstream() {
+	defer close(r.done)
+
+	logger := plog.Logger(r.updater.Log)
+	for {
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-r.changed:
+		}
+
+		r.mutex.Lock()
+		values, replaced, err := r.resolve(r.ctx)
+		r.mutex.Unlock()
+
+		switch {
+		case err != nil:
+			logger.E(fmt.Sprintf("Parameter %q of set %q changed, but the new value can't be used: %v",
+				r.paramName, r.setName, err))
+			r.updater.ReportError(err)
+		case replaced:
+			logger.I(fmt.Sprintf("Parameter %q of set %q changed, updating parameters",
+				r.paramName, r.setName))
+			r.updater.Update(values)
+		}
+	}
+}
+
+// resolve reads the value of the parameter and, if it changed, replaces the
+// current provider. The values of the new provider are returned. If the
+// value did not change, the values of the current provider are returned.
+// Caller must hold the mutex.
+func (r *chainProvider) resolve(ctx context.Context) (types.ParamValues, bool, error) {
+	value, err := r.updater.Peek(r.setName, r.paramName)
+	if err != nil {
+		return nil, false, err
+	}
+
+	r.current.mutex.Lock()
+	generation := r.current.generation
+	currentValues := r.current.values.Copy()
+	r.current.mutex.Unlock()
+
+	if generation > 0 && paramvalues.EqualValue(value, r.value) {
+		return currentValues, false, nil
+	}
+
+	var provider sources.Provider
+	var values types.ParamValues
+	generation++
+	if value != nil && *value != "" {
+		provider, err = r.factory(*value)
+		if err != nil {
+			return nil, false, err
+		}
+
+		values, err = watch(ctx, provider, r.paramIDs, &chainUpdater{
+			Updater:    r.updater,
+			chain:      r,
+			generation: generation,
+		})
+		if err != nil {
+			return values, false, errors.Join(err, stop(context.Background(), provider))
+		}
+	}
+
+	r.current.mutex.Lock()
+	r.current.generation = generation
+	r.current.values = values.Copy()
+	r.current.sourceName = ""
+	if provider != nil {
+		r.current.sourceName = sources.SourceName(provider)
+	}
+	r.current.mutex.Unlock()
+
+	if err := stop(ctx, r.provider); err != nil {
+		plog.Logger(r.updater.Log).E(fmt.Sprintf("Stopping replaced provider: %v", err))
+	}
+
+	r.value = value
+	r.provider = provider
+
+	return values, true, nil
+}
+
+// chainUpdater is the updater passed to the providers created by the
+// factory. Updates from replaced providers are ignored.
+type chainUpdater struct {
+	sources.Updater
+	chain      *chainProvider
+	generation int
+}
+
+func (u *chainUpdater) Update(v types.ParamValues) {
+	if u.current(v) {
+		u.Updater.Update(v)
+	}
+}
+
+func (u *chainUpdater) UpdateWithResult(v types.ParamValues) error {
+	if !u.current(v) {
+		return errors.New("provider was replaced")
+	}
+
+	return u.Updater.UpdateWithResult(v)
+}
+
+// current records the values if they are from the current provider.
+func (u *chainUpdater) current(v types.ParamValues) bool {
+	u.chain.current.mutex.Lock()
+	defer u.chain.current.mutex.Unlock()
+
+	if u.generation != u.chain.current.generation {
+		return false
+	}
+
+	u.chain.current.values = v.Copy()
+	return true
+}
+
+func watch(
+	ctx context.Context,
+	provider sources.Provider,
+	paramIDs sources.Parameters,
+	updater sources.Updater,
+) (types.ParamValues, error) {
+	if watcher, ok := provider.(sources.ContextWatcher); ok {
+		return watcher.WatchContext(ctx, paramIDs, updater)
+	}
+
+	return provider.Watch(paramIDs, updater)
+}
+
+func stop(ctx context.Context, provider sources.Provider) error {
+	if provider == nil {
+		return nil
+	}
+
+	if stopper, ok := provider.(sources.ContextStopper); ok {
+		return stopper.StopContext(ctx)
+	}
+
+	provider.Stop()
+	return nil
+}
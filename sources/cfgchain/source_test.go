@@ -0,0 +1,144 @@
+//go:build unittest || !integrationtest
+// +build unittest !integrationtest
+
+package cfgchain_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/simplesurance/proteus"
+	"github.com/simplesurance/proteus/internal/assert"
+	"github.com/simplesurance/proteus/sources"
+	"github.com/simplesurance/proteus/sources/cfgchain"
+	"github.com/simplesurance/proteus/sources/cfgenv"
+	"github.com/simplesurance/proteus/sources/cfgfile"
+	"github.com/simplesurance/proteus/sources/cfgtest"
+	"github.com/simplesurance/proteus/types"
+	"github.com/simplesurance/proteus/xtypes"
+)
+
+func TestChain(t *testing.T) {
+	dir := t.TempDir()
+	file1 := filepath.Join(dir, "config1.json")
+	file2 := filepath.Join(dir, "config2.json")
+	assert.NoErrorNow(t, os.WriteFile(file1, []byte(`{"level": 1}`), 0o600))
+	assert.NoErrorNow(t, os.WriteFile(file2, []byte(`{"level": 2}`), 0o600))
+
+	params := struct {
+		ConfigFile *xtypes.String       `param:"config-file"`
+		Level      *xtypes.Integer[int] `param:",optional"`
+	}{}
+
+	testProvider := cfgtest.New(types.ParamValues{
+		"": {"config-file": file1},
+	})
+
+	parsed, err := proteus.MustParse(&params,
+		proteus.WithProviders(
+			testProvider,
+			cfgchain.New("", "config-file", newFileProvider)))
+	assert.NoErrorNow(t, err)
+	defer func() {
+		assert.NoError(t, parsed.Stop(context.Background()))
+	}()
+
+	assert.Equal(t, 1, params.Level.Value())
+
+	// changing the parameter replaces the provider
+	testProvider.Update("", "config-file", &file2)
+	assert.Eventually(t, 2*time.Second, func() bool { return params.Level.Value() == 2 }, "value must be updated")
+
+	// invalid values are reported, and the current provider is kept
+	missing := filepath.Join(dir, "missing.json")
+	testProvider.Update("", "config-file", &missing)
+	assert.Eventually(t, 2*time.Second, func() bool { return !parsed.Providers()[1].Healthy() }, "provider must report the error")
+	assert.Equal(t, 2, params.Level.Value())
+
+	// the values of the provider can also change
+	assert.NoErrorNow(t, os.WriteFile(file1, []byte(`{"level": 3}`), 0o600))
+	testProvider.Update("", "config-file", &file1)
+	assert.Eventually(t, 2*time.Second, func() bool { return params.Level.Value() == 3 }, "value must be updated")
+}
+
+func TestChainSourceName(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	assert.NoErrorNow(t, os.WriteFile(path, []byte(`{"level": 1}`), 0o600))
+
+	// values are identified by the provider created by the factory
+	params := struct {
+		ConfigFile string `param:"config-file"`
+		Level      int    `param:",sources=file"`
+	}{}
+
+	parsed, err := proteus.MustParse(&params,
+		proteus.WithProviders(
+			cfgtest.New(types.ParamValues{"": {"config-file": path}}),
+			cfgchain.New("", "config-file", newFileProvider)))
+	assert.NoErrorNow(t, err)
+	defer func() {
+		assert.NoError(t, parsed.Stop(context.Background()))
+	}()
+
+	assert.Equal(t, 1, params.Level)
+}
+
+func TestChainNotProvided(t *testing.T) {
+	params := struct {
+		ConfigFile string `param:"config-file,optional"`
+		Level      int    `param:",optional"`
+	}{
+		Level: 42,
+	}
+
+	parsed, err := proteus.MustParse(&params,
+		proteus.WithProviders(
+			cfgtest.New(types.ParamValues{}),
+			cfgchain.New("", "config-file", newFileProvider)))
+	assert.NoErrorNow(t, err)
+	defer func() {
+		assert.NoError(t, parsed.Stop(context.Background()))
+	}()
+
+	assert.Equal(t, 42, params.Level)
+}
+
+func TestChainReload(t *testing.T) {
+	dir := t.TempDir()
+	file1 := filepath.Join(dir, "config1.json")
+	file2 := filepath.Join(dir, "config2.json")
+	assert.NoErrorNow(t, os.WriteFile(file1, []byte(`{"level": 1}`), 0o600))
+	assert.NoErrorNow(t, os.WriteFile(file2, []byte(`{"level": 10}`), 0o600))
+	t.Setenv("CHAINTEST__CONFIG_FILE", file1)
+
+	params := struct {
+		ConfigFile string               `param:"config-file"`
+		Level      *xtypes.Integer[int] `param:",optional"`
+	}{}
+
+	parsed, err := proteus.MustParse(&params,
+		proteus.WithProviders(
+			cfgenv.New("CHAINTEST"),
+			cfgchain.New("", "config-file", newFileProvider)))
+	assert.NoErrorNow(t, err)
+	defer func() {
+		assert.NoError(t, parsed.Stop(context.Background()))
+	}()
+
+	// the values of the current provider are reloaded
+	assert.NoErrorNow(t, os.WriteFile(file1, []byte(`{"level": 2}`), 0o600))
+	assert.NoErrorNow(t, parsed.Reload(context.Background()))
+	assert.Equal(t, 2, params.Level.Value())
+
+	// the provider is replaced when the parameter is reloaded
+	t.Setenv("CHAINTEST__CONFIG_FILE", file2)
+	assert.NoErrorNow(t, parsed.Reload(context.Background()))
+	assert.Equal(t, 10, params.Level.Value())
+}
+
+func newFileProvider(path string) (sources.Provider, error) {
+	return cfgfile.New(path, cfgfile.WithPollInterval(0)), nil
+}
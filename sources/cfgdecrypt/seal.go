@@ -0,0 +1,121 @@
+package cfgdecrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Prefix identifies encrypted values.
+const Prefix = "enc:"
+
+// info is used to derive the encryption key, binding it to its purpose.
+const info = "proteus sealed value v1"
+
+// Encrypt encrypts the value for the owner of the private key matching
+// pub. The returned string has the "enc:" prefix, and can be used as the
+// value of a parameter.
+//
+// A new ephemeral key pair is generated for each value. The key used to
+// encrypt the value with AES-256-GCM is derived with HKDF-SHA256 from the
+// shared secret between the ephemeral private key and pub. The encoded
+// value has the ephemeral public key followed by the ciphertext.
+func Encrypt(pub *ecdh.PublicKey, value []byte) (string, error) {
+	if pub == nil || pub.Curve() != ecdh.X25519() {
+		return "", errors.New("public key must be a X25519 key")
+	}
+
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", fmt.Errorf("generating ephemeral key: %w", err)
+	}
+
+	shared, err := ephemeral.ECDH(pub)
+	if err != nil {
+		return "", err
+	}
+
+	aead, err := newAEAD(shared, ephemeral.PublicKey().Bytes(), pub.Bytes())
+	if err != nil {
+		return "", err
+	}
+
+	// the key is used for a single message, so the nonce can be fixed
+	nonce := make([]byte, aead.NonceSize())
+	sealed := aead.Seal(ephemeral.PublicKey().Bytes(), nonce, value, nil)
+
+	return Prefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt decrypts a value encrypted with Encrypt. The value must have the
+// "enc:" prefix.
+func Decrypt(priv *ecdh.PrivateKey, value string) ([]byte, error) {
+	if priv == nil || priv.Curve() != ecdh.X25519() {
+		return nil, errors.New("private key must be a X25519 key")
+	}
+
+	encoded, ok := strings.CutPrefix(value, Prefix)
+	if !ok {
+		return nil, fmt.Errorf("value does not have the %q prefix", Prefix)
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errors.New("value is not valid base64")
+	}
+
+	const keySize = 32
+	if len(sealed) < keySize {
+		return nil, errors.New("value is too short")
+	}
+
+	ephemeral, err := ecdh.X25519().NewPublicKey(sealed[:keySize])
+	if err != nil {
+		return nil, err
+	}
+
+	shared, err := priv.ECDH(ephemeral)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := newAEAD(shared, ephemeral.Bytes(), priv.PublicKey().Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	ret, err := aead.Open(nil, nonce, sealed[keySize:], nil)
+	if err != nil {
+		return nil, errors.New("value was not encrypted for this key or was modified")
+	}
+
+	return ret, nil
+}
+
+// newAEAD creates the cipher for the shared secret between the ephemeral
+// and the recipient keys.
+func newAEAD(shared, ephemeralPub, recipientPub []byte) (cipher.AEAD, error) {
+	salt := make([]byte, 0, len(ephemeralPub)+len(recipientPub))
+	salt = append(salt, ephemeralPub...)
+	salt = append(salt, recipientPub...)
+
+	key, err := hkdf.Key(sha256.New, shared, salt, info, 32)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,266 @@
+// Package cfgdecrypt is a parameter provider that wraps another provider,
+// decrypting the values it provides. This allows, for example, committing
+// configuration files with secrets to version control.
+//
+// Encrypted values have the "enc:" prefix, and are created with Encrypt,
+// for the public key matching the X25519 private key used to decrypt them.
+// Values without the prefix are provided unchanged:
+//
+//	{
+//		"db": {
+//			"user": "app",
+//			"pwd": "enc:2T4E..."
+//		}
+//	}
+//
+// The private key is set on a xtypes.X25519PrivateKey before parsing, see
+// New, or provided as a parameter by the providers registered before this
+// one, in the formats accepted by xtypes.X25519PrivateKey, see
+// NewFromParam. Values that can't be decrypted result in violations that
+// identify the parameter, but not the reason or the value.
+//
+// Parameters receiving encrypted values should be secrets, to make sure
+// that the decrypted values are not leaked.
+package cfgdecrypt
+
+import (
+	"context"
+	"crypto/ecdh"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/simplesurance/proteus/plog"
+	"github.com/simplesurance/proteus/sources"
+	"github.com/simplesurance/proteus/types"
+	"github.com/simplesurance/proteus/xtypes"
+)
+
+// New wraps provider, decrypting its values with the key held by key. See
+// package description for details.
+//
+// The provider starts before the configuration struct is filled, so key
+// must already hold a key when MustParse is called, usually on its
+// DefaultValue. A xtypes.X25519PrivateKey declared on the same
+// configuration struct is still empty at that point; use NewFromParam to
+// read the key from a parameter.
+func New(provider sources.Provider, key *xtypes.X25519PrivateKey) sources.Provider {
+	return &decryptProvider{
+		provider: provider,
+		keyFn: func(sources.Updater) (*ecdh.PrivateKey, error) {
+			var ret *ecdh.PrivateKey
+			if key != nil {
+				ret = key.Value()
+			}
+
+			if ret == nil {
+				return nil, errors.New("key to decrypt values is not set; " +
+					"keys read from parameters must be provided with NewFromParam")
+			}
+
+			return ret, nil
+		},
+	}
+}
+
+// NewFromParam wraps provider, decrypting its values with the key provided
+// as the value of a parameter by the providers registered before this one.
+// The parameter must be declared on the configuration struct, like any
+// other parameter, preferably as a xtypes.X25519PrivateKey. See package
+// description for details.
+func NewFromParam(provider sources.Provider, setName, paramName string) sources.Provider {
+	return &decryptProvider{
+		provider: provider,
+		keyFn: func(updater sources.Updater) (*ecdh.PrivateKey, error) {
+			value, err := updater.Peek(setName, paramName)
+			if err != nil {
+				return nil, err
+			}
+
+			if value == nil || *value == "" {
+				return nil, fmt.Errorf(
+					"key to decrypt values is not set on parameter %q of set %q",
+					paramName, setName)
+			}
+
+			key := &xtypes.X25519PrivateKey{}
+			if err := key.UnmarshalParam(value); err != nil {
+				return nil, fmt.Errorf("parameter %q of set %q: %w", paramName, setName, err)
+			}
+
+			return key.Value(), nil
+		},
+	}
+}
+
+type decryptProvider struct {
+	provider sources.Provider
+	keyFn    func(sources.Updater) (*ecdh.PrivateKey, error)
+
+	updater sources.Updater
+
+	// mutex protects the last values received from the provider, still
+	// encrypted
+	mutex sync.Mutex
+	raw   types.ParamValues
+}
+
+var (
+	_ sources.ContextWatcher = &decryptProvider{}
+	_ sources.ContextStopper = &decryptProvider{}
+	_ sources.Reloader       = &decryptProvider{}
+	_ sources.SourceNamer    = &decryptProvider{}
+)
+
+func (r *decryptProvider) IsCommandLineFlag() bool {
+	return r.provider.IsCommandLineFlag()
+}
+
+// SourceName identifies the wrapped provider, so restrictions on from where
+// parameters can be read are not affected by decrypting them.
+func (r *decryptProvider) SourceName() string {
+	return sources.SourceName(r.provider)
+}
+
+func (r *decryptProvider) Stop() {
+	r.provider.Stop()
+}
+
+// StopContext stops the wrapped provider.
+func (r *decryptProvider) StopContext(ctx context.Context) error {
+	if stopper, ok := r.provider.(sources.ContextStopper); ok {
+		return stopper.StopContext(ctx)
+	}
+
+	r.provider.Stop()
+	return nil
+}
+
+func (r *decryptProvider) Watch(
+	paramIDs sources.Parameters,
+	updater sources.Updater,
+) (initial types.ParamValues, _ error) {
+	return r.WatchContext(context.Background(), paramIDs, updater)
+}
+
+// WatchContext starts the wrapped provider, passing ctx to it if it
+// implements sources.ContextWatcher.
+func (r *decryptProvider) WatchContext(
+	ctx context.Context,
+	paramIDs sources.Parameters,
+	updater sources.Updater,
+) (initial types.ParamValues, _ error) {
+	r.updater = updater
+
+	wrapped := &decryptUpdater{Updater: updater, provider: r}
+
+	var err error
+	if watcher, ok := r.provider.(sources.ContextWatcher); ok {
+		initial, err = watcher.WatchContext(ctx, paramIDs, wrapped)
+	} else {
+		initial, err = r.provider.Watch(paramIDs, wrapped)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return r.decrypt(initial)
+}
+
+// Reload reloads the wrapped provider, if it implements sources.Reloader,
+// and decrypts the values again, since the key may have changed.
+func (r *decryptProvider) Reload(ctx context.Context) (types.ParamValues, error) {
+	reloader, ok := r.provider.(sources.Reloader)
+	if !ok {
+		r.mutex.Lock()
+		raw := r.raw
+		r.mutex.Unlock()
+
+		return r.decrypt(raw)
+	}
+
+	values, err := reloader.Reload(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.decrypt(values)
+}
+
+// decrypt records the values received from the provider, and returns them
+// decrypted.
+func (r *decryptProvider) decrypt(values types.ParamValues) (types.ParamValues, error) {
+	r.mutex.Lock()
+	r.raw = values.Copy()
+	r.mutex.Unlock()
+
+	ret := values.Copy()
+
+	var key *ecdh.PrivateKey
+	var violations types.ErrViolations
+	for setName, set := range ret {
+		for paramName, value := range set {
+			if !strings.HasPrefix(value, Prefix) {
+				continue
+			}
+
+			// the key is only needed if there are encrypted values
+			if key == nil {
+				var err error
+				key, err = r.keyFn(r.updater)
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			decrypted, err := Decrypt(key, value)
+			if err != nil {
+				delete(set, paramName)
+				violations = append(violations, types.Violation{
+					SetName:   setName,
+					ParamName: paramName,
+					Message:   "value could not be decrypted",
+				})
+				continue
+			}
+
+			set[paramName] = string(decrypted)
+		}
+	}
+
+	if len(violations) > 0 {
+		return ret, violations
+	}
+
+	return ret, nil
+}
+
+// decryptUpdater is the updater passed to the wrapped provider, decrypting
+// its updates.
+type decryptUpdater struct {
+	sources.Updater
+	provider *decryptProvider
+}
+
+func (u *decryptUpdater) Update(v types.ParamValues) {
+	decrypted, err := u.provider.decrypt(v)
+	if err != nil {
+		plog.Logger(u.Log).E(fmt.Sprintf("Ignoring update: %v", err))
+		u.ReportError(err)
+		return
+	}
+
+	u.Updater.Update(decrypted)
+}
+
+func (u *decryptUpdater) UpdateWithResult(v types.ParamValues) error {
+	decrypted, err := u.provider.decrypt(v)
+	if err != nil {
+		u.ReportError(err)
+		return err
+	}
+
+	return u.Updater.UpdateWithResult(decrypted)
+}
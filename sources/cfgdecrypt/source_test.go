@@ -0,0 +1,176 @@
+//go:build unittest || !integrationtest
+// +build unittest !integrationtest
+
+package cfgdecrypt_test
+
+import (
+	"context"
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/simplesurance/proteus"
+	"github.com/simplesurance/proteus/internal/assert"
+	"github.com/simplesurance/proteus/sources/cfgdecrypt"
+	"github.com/simplesurance/proteus/sources/cfgtest"
+	"github.com/simplesurance/proteus/types"
+	"github.com/simplesurance/proteus/xtypes"
+)
+
+func TestEncryptDecrypt(t *testing.T) {
+	key := newKey(t)
+	otherKey := newKey(t)
+
+	encrypted, err := cfgdecrypt.Encrypt(key.PublicKey(), []byte("s3cr3t"))
+	assert.NoErrorNow(t, err)
+	assert.True(t, strings.HasPrefix(encrypted, "enc:"), "must have the prefix")
+
+	decrypted, err := cfgdecrypt.Decrypt(key, encrypted)
+	assert.NoErrorNow(t, err)
+	assert.Equal(t, "s3cr3t", string(decrypted))
+
+	// each value is encrypted with a different ephemeral key
+	again, err := cfgdecrypt.Encrypt(key.PublicKey(), []byte("s3cr3t"))
+	assert.NoErrorNow(t, err)
+	assert.True(t, again != encrypted, "encrypted values must differ")
+
+	_, err = cfgdecrypt.Decrypt(otherKey, encrypted)
+	assert.Error(t, err)
+
+	modified := encrypted[:len(encrypted)-4] + "AAA="
+	_, err = cfgdecrypt.Decrypt(key, modified)
+	assert.Error(t, err)
+}
+
+func TestDecryptProvider(t *testing.T) {
+	key := newKey(t)
+	pwd1 := encrypt(t, key, "s3cr3t")
+	pwd2 := encrypt(t, key, "changed")
+
+	params := struct {
+		User string
+		Pwd  *xtypes.String `param:",secret,sources=test"`
+	}{}
+
+	provider := cfgtest.New(types.ParamValues{
+		"": {"user": "app", "pwd": pwd1},
+	})
+
+	parsed, err := proteus.MustParse(&params,
+		proteus.WithProviders(cfgdecrypt.New(provider,
+			&xtypes.X25519PrivateKey{DefaultValue: key})))
+	assert.NoErrorNow(t, err)
+	defer func() {
+		assert.NoError(t, parsed.Stop(context.Background()))
+	}()
+
+	assert.Equal(t, "app", params.User)
+	assert.Equal(t, "s3cr3t", params.Pwd.Value())
+
+	// updates are decrypted
+	assert.NoErrorNow(t, provider.UpdateWithResult("", "pwd", &pwd2))
+	assert.Equal(t, "changed", params.Pwd.Value())
+
+	// values that can't be decrypted are rejected
+	invalid := encrypt(t, newKey(t), "other")
+	err = provider.UpdateWithResult("", "pwd", &invalid)
+	assert.ErrorNow(t, err)
+	assert.Equal(t, "changed", params.Pwd.Value())
+}
+
+func TestDecryptProviderKeyFromParam(t *testing.T) {
+	key := newKey(t)
+	pwd := encrypt(t, key, "s3cr3t")
+
+	params := struct {
+		Key *xtypes.X25519PrivateKey `param:",secret"`
+		Pwd string                   `param:",secret"`
+	}{}
+
+	parsed, err := proteus.MustParse(&params,
+		proteus.WithProviders(
+			cfgtest.New(types.ParamValues{
+				"": {"key": hex.EncodeToString(key.Bytes())},
+			}),
+			cfgdecrypt.NewFromParam(cfgtest.New(types.ParamValues{
+				"": {"pwd": pwd},
+			}), "", "key")))
+	assert.NoErrorNow(t, err)
+	defer func() {
+		assert.NoError(t, parsed.Stop(context.Background()))
+	}()
+
+	assert.Equal(t, "s3cr3t", params.Pwd)
+}
+
+func TestDecryptProviderKeyOnStruct(t *testing.T) {
+	key := newKey(t)
+	pwd := encrypt(t, key, "s3cr3t")
+
+	params := struct {
+		Key *xtypes.X25519PrivateKey `param:",secret"`
+		Pwd string                   `param:",secret"`
+	}{}
+	params.Key = &xtypes.X25519PrivateKey{}
+
+	// the key on the struct is only set after the providers start, so it
+	// can't be used with New
+	parsed, err := proteus.MustParse(&params,
+		proteus.WithProviders(
+			cfgtest.New(types.ParamValues{
+				"": {"key": hex.EncodeToString(key.Bytes())},
+			}),
+			cfgdecrypt.New(cfgtest.New(types.ParamValues{
+				"": {"pwd": pwd},
+			}), params.Key)))
+	assert.ErrorNow(t, err)
+	defer parsed.Stop(context.Background()) //nolint:errcheck
+
+	assert.StringContains(t, err.Error(), "NewFromParam")
+}
+
+func TestDecryptProviderViolation(t *testing.T) {
+	pwd := encrypt(t, newKey(t), "s3cr3t")
+
+	params := struct {
+		DB struct {
+			Pwd string `param:",secret"`
+		} `param:"db"`
+	}{}
+
+	parsed, err := proteus.MustParse(&params,
+		proteus.WithProviders(cfgdecrypt.New(
+			cfgtest.New(types.ParamValues{"db": {"pwd": pwd}}),
+			&xtypes.X25519PrivateKey{DefaultValue: newKey(t)})))
+	assert.ErrorNow(t, err)
+	defer parsed.Stop(context.Background()) //nolint:errcheck
+
+	var violations types.ErrViolations
+	assert.TrueNow(t, errors.As(err, &violations), "error must be violations")
+	assert.EqualNow(t, 1, len(violations))
+	assert.Equal(t, "db", violations[0].SetName)
+	assert.Equal(t, "pwd", violations[0].ParamName)
+
+	encoded := strings.TrimPrefix(pwd, "enc:")
+	assert.True(t, !strings.Contains(err.Error(), encoded[:8]),
+		"error must not contain the encrypted value")
+}
+
+func newKey(t *testing.T) *ecdh.PrivateKey {
+	t.Helper()
+
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	assert.NoErrorNow(t, err)
+	return key
+}
+
+func encrypt(t *testing.T, key *ecdh.PrivateKey, value string) string {
+	t.Helper()
+
+	ret, err := cfgdecrypt.Encrypt(key.PublicKey(), []byte(value))
+	assert.NoErrorNow(t, err)
+	return ret
+}
@@ -0,0 +1,396 @@
+// Package cfgdir is a parameter provider that reads values from a
+// directory with one file per parameter, like the volumes on which
+// Kubernetes mounts ConfigMaps and Secrets.
+//
+// Files are mapped to parameters as follows:
+//
+//	<dir>/<param>          (if parameter is not on a set)
+//	<dir>/<set>__<param>   (if parameter is on a set)
+//	<dir>/<set>/<param>    (if parameter is on a set)
+//
+// The content of the file is the value of the parameter. Files and
+// directories whose name starts with "." are ignored. All other files must
+// match a parameter, allowing the detection of small mistakes like typos.
+//
+// The directory is polled for changes. Kubernetes updates the volume by
+// writing the new files to a new directory and atomically replacing the
+// "..data" symbolic link to point to it. When this link exists, the files
+// are only read when it changes, and always from the directory it points
+// to, so all changes made by Kubernetes result in a single update, instead
+// of one for each changed file.
+package cfgdir
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/simplesurance/proteus/internal/paramvalues"
+	"github.com/simplesurance/proteus/plog"
+	"github.com/simplesurance/proteus/sources"
+	"github.com/simplesurance/proteus/types"
+)
+
+// DefaultPollInterval is how often the directory is checked for changes, if
+// not specified with WithPollInterval.
+const DefaultPollInterval = 5 * time.Second
+
+// dataLink is the symbolic link that Kubernetes replaces when the content
+// of the volume changes.
+const dataLink = "..data"
+
+// New creates a new provider that reads parameters from the files in dir.
+// See package description for details.
+func New(dir string, opts ...Option) sources.Provider {
+	ret := &dirProvider{
+		dir:          dir,
+		pollInterval: DefaultPollInterval,
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+
+	for _, o := range opts {
+		o(ret)
+	}
+
+	return ret
+}
+
+// Option specifies options for the provider.
+type Option func(*dirProvider)
+
+// WithPollInterval specifies how often the directory is checked for
+// changes. If not positive, the directory is not watched for changes.
+func WithPollInterval(interval time.Duration) Option {
+	return func(p *dirProvider) {
+		p.pollInterval = interval
+	}
+}
+
+// WithTrimTrailingNewline removes one line break from the end of the
+// values, if present. Files created with editors and with "echo" usually
+// end with one.
+func WithTrimTrailingNewline() Option {
+	return func(p *dirProvider) {
+		p.trimNewline = true
+	}
+}
+
+type dirProvider struct {
+	dir          string
+	pollInterval time.Duration
+	trimNewline  bool
+
+	paramIDs sources.Parameters
+
+	mutex    sync.Mutex
+	target   string
+	snapshot map[string][]byte
+
+	started  atomic.Bool
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+var (
+	_ sources.Reloader       = &dirProvider{}
+	_ sources.ContextStopper = &dirProvider{}
+)
+
+// errChanged is returned when the data link changes while the files are
+// being read.
+var errChanged = errors.New("directory changed while being read")
+
+func (r *dirProvider) IsCommandLineFlag() bool {
+	return false
+}
+
+func (r *dirProvider) Stop() {
+	_ = r.StopContext(context.Background())
+}
+
+// StopContext stops watching the directory for changes.
+func (r *dirProvider) StopContext(ctx context.Context) error {
+	r.stopOnce.Do(func() {
+		close(r.stop)
+	})
+
+	if !r.started.Load() {
+		return nil
+	}
+
+	select {
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *dirProvider) Watch(
+	paramIDs sources.Parameters,
+	updater sources.Updater,
+) (initial types.ParamValues, _ error) {
+	r.paramIDs = paramIDs
+
+	initial, _, err := r.read(true)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.pollInterval <= 0 {
+		return initial, nil
+	}
+
+	r.started.Store(true)
+	go r.watch(updater)
+
+	return initial, nil
+}
+
+// Reload reads the directory again.
+func (r *dirProvider) Reload(_ context.Context) (types.ParamValues, error) {
+	values, _, err := r.read(true)
+	return values, err
+}
+
+func (r *dirProvider) watch(updater sources.Updater) {
+	defer close(r.done)
+
+	logger := plog.Logger(updater.Log)
+
+	ticker := time.NewTicker(r.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+		}
+
+		values, changed, err := r.read(false)
+		switch {
+		case errors.Is(err, errChanged):
+			// read again on the next poll, after the change is complete
+		case err != nil:
+			logger.E(fmt.Sprintf("Ignoring changes on %s: %v", r.dir, err))
+			updater.ReportError(err)
+		case changed:
+			logger.I(fmt.Sprintf("%s changed, updating parameters", r.dir))
+			updater.Update(values)
+		}
+	}
+}
+
+// read reads the files from the directory, and reports if they changed
+// since the last time they were read. Unless force is true, files are not
+// read if the data link exists and did not change.
+func (r *dirProvider) read(force bool) (types.ParamValues, bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var target string
+	var snapshot map[string][]byte
+	var err error
+	for range 3 {
+		target, snapshot, err = r.readSnapshot(force)
+		if !errors.Is(err, errChanged) {
+			break
+		}
+	}
+
+	if err != nil || snapshot == nil {
+		return nil, false, err
+	}
+
+	changed := force || !maps.EqualFunc(snapshot, r.snapshot, bytes.Equal)
+
+	// recorded even if invalid, so the same problem is reported only once
+	r.target = target
+	r.snapshot = snapshot
+
+	values, err := r.parse(snapshot)
+	return values, changed, err
+}
+
+// readSnapshot reads the files from the directory. If the data link exists,
+// the files are read from the directory it points to, and nil is returned
+// if it did not change and force is false.
+func (r *dirProvider) readSnapshot(force bool) (string, map[string][]byte, error) {
+	target, err := os.Readlink(filepath.Join(r.dir, dataLink))
+	switch {
+	case err == nil:
+		if !force && target == r.target {
+			return target, nil, nil
+		}
+	case errors.Is(err, os.ErrNotExist):
+		target = ""
+	default:
+		return "", nil, err
+	}
+
+	root := r.dir
+	if target != "" {
+		if filepath.IsAbs(target) {
+			root = target
+		} else {
+			root = filepath.Join(r.dir, target)
+		}
+	}
+
+	snapshot, err := readFiles(root)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if target != "" {
+		// make sure that all files are from the same version
+		current, err := os.Readlink(filepath.Join(r.dir, dataLink))
+		if err != nil || current != target {
+			return "", nil, errChanged
+		}
+	}
+
+	return target, snapshot, nil
+}
+
+// parse maps the files to parameters.
+func (r *dirProvider) parse(snapshot map[string][]byte) (types.ParamValues, error) {
+	names := make([]string, 0, len(snapshot))
+	for name := range snapshot {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	ret := types.ParamValues{}
+	var violations types.ErrViolations
+	for _, name := range names {
+		setName, paramName := paramID(name)
+		content := snapshot[name]
+		if _, ok := r.paramIDs.Get(setName, paramName); !ok || content == nil {
+			violations = append(violations, types.Violation{
+				Message: fmt.Sprintf(
+					"File %q on %s does not match any expected application parameter",
+					name, r.dir),
+			})
+			continue
+		}
+
+		value := string(content)
+		if r.trimNewline {
+			if v, ok := strings.CutSuffix(value, "\n"); ok {
+				value = strings.TrimSuffix(v, "\r")
+			}
+		}
+
+		paramvalues.Set(ret, setName, paramName, value)
+	}
+
+	if len(violations) > 0 {
+		return ret, violations
+	}
+
+	return ret, nil
+}
+
+// paramID returns the set and parameter name a file name maps to.
+func paramID(name string) (setName, paramName string) {
+	if setName, paramName, ok := strings.Cut(name, "/"); ok {
+		return setName, paramName
+	}
+
+	if setName, paramName, ok := strings.Cut(name, "__"); ok {
+		return setName, paramName
+	}
+
+	return "", name
+}
+
+// readFiles reads the files on root and on its subdirectories, returning
+// their content by their path relative to root. Symbolic links are
+// followed. Directories nested deeper than one level are returned with nil
+// content, so they are reported as unknown.
+func readFiles(root string) (map[string][]byte, error) {
+	ret := map[string][]byte{}
+
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
+		path := filepath.Join(root, entry.Name())
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if !info.IsDir() {
+			content, err := readFile(path)
+			if err != nil {
+				return nil, err
+			}
+
+			ret[entry.Name()] = content
+			continue
+		}
+
+		subEntries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, subEntry := range subEntries {
+			if strings.HasPrefix(subEntry.Name(), ".") {
+				continue
+			}
+
+			name := entry.Name() + "/" + subEntry.Name()
+			subPath := filepath.Join(path, subEntry.Name())
+			info, err := os.Stat(subPath)
+			if err != nil {
+				return nil, err
+			}
+
+			if info.IsDir() {
+				ret[name] = nil
+				continue
+			}
+
+			content, err := readFile(subPath)
+			if err != nil {
+				return nil, err
+			}
+
+			ret[name] = content
+		}
+	}
+
+	return ret, nil
+}
+
+// readFile reads a file, never returning nil content for existing files.
+func readFile(path string) ([]byte, error) {
+	content, err := os.ReadFile(path)
+	if content == nil {
+		content = []byte{}
+	}
+
+	return content, err
+}
@@ -0,0 +1,166 @@
+//go:build unittest || !integrationtest
+// +build unittest !integrationtest
+
+package cfgdir_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/simplesurance/proteus"
+	"github.com/simplesurance/proteus/internal/assert"
+	"github.com/simplesurance/proteus/internal/testfile"
+	"github.com/simplesurance/proteus/sources/cfgdir"
+	"github.com/simplesurance/proteus/types"
+	"github.com/simplesurance/proteus/xtypes"
+)
+
+func TestCfgDir(t *testing.T) {
+	dir := t.TempDir()
+	testfile.Write(t, filepath.Join(dir, "name"), "app\n")
+	testfile.Write(t, filepath.Join(dir, "http__address"), ":8080\r\n")
+	testfile.Write(t, filepath.Join(dir, "db", "password"), "s3cr3t")
+	testfile.Write(t, filepath.Join(dir, ".hidden"), "ignored")
+
+	params := struct {
+		Name string
+		HTTP struct {
+			Address string
+		} `param:"http"`
+		DB struct {
+			Password *xtypes.Secret
+		} `param:"db"`
+	}{}
+
+	parsed, err := proteus.MustParse(&params,
+		proteus.WithProviders(cfgdir.New(dir,
+			cfgdir.WithTrimTrailingNewline(),
+			cfgdir.WithPollInterval(10*time.Millisecond))))
+	assert.NoErrorNow(t, err)
+	defer func() {
+		assert.NoError(t, parsed.Stop(context.Background()))
+	}()
+
+	assert.Equal(t, "app", params.Name)
+	assert.Equal(t, ":8080", params.HTTP.Address)
+	assert.Equal(t, "s3cr3t", params.DB.Password.Reveal())
+
+	// changes are applied to xtypes
+	testfile.Write(t, filepath.Join(dir, "db", "password"), "changed")
+	assert.Eventually(t, 2*time.Second, func() bool { return params.DB.Password.Reveal() == "changed" }, "value must be updated")
+
+	// unknown files are rejected
+	testfile.Write(t, filepath.Join(dir, "db", "pasword"), "typo")
+	assert.Eventually(t, 2*time.Second, func() bool {
+		return !parsed.Providers()[0].Healthy()
+	}, "provider must report the error")
+}
+
+func TestCfgDirNoTrim(t *testing.T) {
+	dir := t.TempDir()
+	testfile.Write(t, filepath.Join(dir, "name"), "app\n")
+
+	params := struct {
+		Name string
+	}{}
+
+	parsed, err := proteus.MustParse(&params,
+		proteus.WithProviders(cfgdir.New(dir, cfgdir.WithPollInterval(0))))
+	assert.NoErrorNow(t, err)
+	defer func() {
+		assert.NoError(t, parsed.Stop(context.Background()))
+	}()
+
+	assert.Equal(t, "app\n", params.Name)
+}
+
+func TestCfgDirUnknownFiles(t *testing.T) {
+	dir := t.TempDir()
+	testfile.Write(t, filepath.Join(dir, "name"), "app")
+	testfile.Write(t, filepath.Join(dir, "nmae"), "typo")
+	testfile.Write(t, filepath.Join(dir, "htpp__address"), ":80")
+	testfile.Write(t, filepath.Join(dir, "http", "nested", "address"), ":80")
+
+	params := struct {
+		Name string
+		HTTP struct {
+			Address string `param:",optional"`
+		} `param:"http"`
+	}{}
+
+	parsed, err := proteus.MustParse(&params,
+		proteus.WithProviders(cfgdir.New(dir, cfgdir.WithPollInterval(0))))
+	assert.ErrorNow(t, err)
+	defer parsed.Stop(context.Background()) //nolint:errcheck
+
+	var violations types.ErrViolations
+	assert.TrueNow(t, errors.As(err, &violations), "error must be violations")
+	assert.Equal(t, 3, len(violations))
+	assert.StringContains(t, err.Error(), "nmae")
+	assert.StringContains(t, err.Error(), "htpp__address")
+	assert.StringContains(t, err.Error(), "http/nested")
+}
+
+// TestCfgDirKubernetes simulates how Kubernetes updates volumes with
+// ConfigMaps, making sure that all changed files result in one update.
+func TestCfgDirKubernetes(t *testing.T) {
+	dir := t.TempDir()
+	writeVersion(t, dir, "..v1", map[string]string{
+		"level":         "1",
+		"http__address": ":8080",
+	})
+
+	for _, name := range []string{"level", "http__address"} {
+		assert.NoErrorNow(t, os.Symlink(filepath.Join("..data", name),
+			filepath.Join(dir, name)))
+	}
+
+	params := struct {
+		Level *xtypes.Integer[int]
+		HTTP  struct {
+			Address *xtypes.String
+		} `param:"http"`
+	}{}
+
+	parsed, err := proteus.MustParse(&params,
+		proteus.WithHistory(10),
+		proteus.WithProviders(cfgdir.New(dir,
+			cfgdir.WithPollInterval(10*time.Millisecond))))
+	assert.NoErrorNow(t, err)
+	defer func() {
+		assert.NoError(t, parsed.Stop(context.Background()))
+	}()
+
+	assert.Equal(t, 1, params.Level.Value())
+	assert.Equal(t, ":8080", params.HTTP.Address.Value())
+
+	writeVersion(t, dir, "..v2", map[string]string{
+		"level":         "2",
+		"http__address": ":9090",
+	})
+
+	assert.Eventually(t, 2*time.Second, func() bool { return params.Level.Value() == 2 }, "value must be updated")
+	assert.Equal(t, ":9090", params.HTTP.Address.Value())
+
+	history := parsed.History()
+	assert.EqualNow(t, 1, len(history))
+	assert.Equal(t, 2, len(history[0].Changes))
+}
+
+// writeVersion writes the files to a new directory and atomically points
+// the "..data" link to it, like Kubernetes does.
+func writeVersion(t *testing.T, dir, version string, files map[string]string) {
+	t.Helper()
+
+	for name, content := range files {
+		testfile.Write(t, filepath.Join(dir, version, name), content)
+	}
+
+	tmpLink := filepath.Join(dir, "..data_tmp")
+	assert.NoErrorNow(t, os.Symlink(version, tmpLink))
+	assert.NoErrorNow(t, os.Rename(tmpLink, filepath.Join(dir, "..data")))
+}
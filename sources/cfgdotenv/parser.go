@@ -0,0 +1,144 @@
+package cfgdotenv
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var varNameRE = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+
+// variable is a variable read from the file.
+type variable struct {
+	value string
+	line  int
+}
+
+// parseFile parses the contents of a dotenv file. When a variable is
+// defined more than once, the last definition is used, like when the file
+// is sourced by a shell.
+func parseFile(path string, data []byte) (map[string]variable, error) {
+	lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+
+	ret := map[string]variable{}
+	for i := 0; i < len(lines); i++ {
+		lineNo := i + 1
+		line := strings.TrimLeft(lines[i], " \t")
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		if rest, ok := strings.CutPrefix(line, "export"); ok && rest != "" &&
+			(rest[0] == ' ' || rest[0] == '\t') {
+			line = strings.TrimLeft(rest, " \t")
+		}
+
+		name, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected NAME=VALUE", path, lineNo)
+		}
+
+		name = strings.TrimRight(name, " \t")
+		if !varNameRE.MatchString(name) {
+			return nil, fmt.Errorf("%s:%d: invalid variable name %q", path, lineNo, name)
+		}
+
+		value = strings.TrimLeft(value, " \t")
+		if value == "" || (value[0] != '"' && value[0] != '\'') {
+			ret[name] = variable{value: unquotedValue(value), line: lineNo}
+			continue
+		}
+
+		parsed, rest, lastLine, err := quotedValue(lines, i, value)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, lineNo, err)
+		}
+
+		rest = strings.TrimLeft(rest, " \t")
+		if rest != "" && rest[0] != '#' {
+			return nil, fmt.Errorf("%s:%d: unexpected characters after the closing quote",
+				path, lastLine+1)
+		}
+
+		ret[name] = variable{value: parsed, line: lineNo}
+		i = lastLine
+	}
+
+	return ret, nil
+}
+
+// unquotedValue returns the value without the comment that may follow it.
+func unquotedValue(value string) string {
+	for i := 1; i < len(value); i++ {
+		if value[i] == '#' && (value[i-1] == ' ' || value[i-1] == '\t') {
+			value = value[:i]
+			break
+		}
+	}
+
+	return strings.TrimRight(value, " \t")
+}
+
+// quotedValue parses a value that starts with a quote on lines[ix], and can
+// continue on the following lines. It returns the value, what follows the
+// closing quote and the index of the line where the value ends.
+func quotedValue(
+	lines []string,
+	ix int,
+	value string,
+) (parsed, rest string, lastLine int, _ error) {
+	quote := value[0]
+	line := value[1:]
+
+	var sb strings.Builder
+	for {
+		continued := false
+		for i := 0; i < len(line); i++ {
+			c := line[i]
+			switch {
+			case c == quote:
+				return sb.String(), line[i+1:], ix, nil
+			case c == '\\' && quote == '"':
+				if i+1 == len(line) {
+					// a backslash at the end of the line joins it with
+					// the next one
+					continued = true
+					break
+				}
+
+				i++
+				sb.WriteString(unescape(line[i]))
+			default:
+				sb.WriteByte(c)
+			}
+		}
+
+		if ix+1 >= len(lines) {
+			return "", "", ix, fmt.Errorf("quoted value is not terminated")
+		}
+
+		if !continued {
+			sb.WriteByte('\n')
+		}
+
+		ix++
+		line = lines[ix]
+	}
+}
+
+// unescape returns what an escape sequence inside double quotes means.
+// Unknown escape sequences are kept unchanged.
+func unescape(c byte) string {
+	switch c {
+	case 'n':
+		return "\n"
+	case 'r':
+		return "\r"
+	case 't':
+		return "\t"
+	case '"', '\\', '$', '`':
+		return string(c)
+	default:
+		return `\` + string(c)
+	}
+}
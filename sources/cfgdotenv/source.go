@@ -0,0 +1,105 @@
+// Package cfgdotenv is a parameter provider that reads values from dotenv
+// (.env) files, usually used during development to avoid having to set
+// environment variables on the shell.
+//
+// Variables are mapped to parameters with the same rules used by cfgenv,
+// and all variables with the prefix must match a parameter. Variables
+// without the prefix are ignored. For example, if the prefix is "cfg":
+//
+//	# comments and blank lines are ignored
+//	CFG__NAME=app
+//	export CFG__HTTP__ADDRESS=":8080"    # "export" is optional
+//	CFG__HTTP__BANNER='literal, no escapes: \n'
+//	CFG__HTTP__CERT="-----BEGIN CERTIFICATE-----
+//	MIIB...
+//	-----END CERTIFICATE-----"
+//
+// Values inside single quotes are used literally. Inside double quotes the
+// escape sequences \n, \r, \t, \", \\, \$ and \` are supported. Quoted
+// values can span multiple lines. Unquoted values end where a comment
+// starts. Variables are not expanded.
+//
+// Optionally, the file can be watched for changes, see WithPollInterval.
+package cfgdotenv
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/simplesurance/proteus/internal/envvar"
+	"github.com/simplesurance/proteus/internal/fileprovider"
+	"github.com/simplesurance/proteus/sources"
+	"github.com/simplesurance/proteus/types"
+)
+
+// New creates a new provider that reads the parameters from the dotenv file
+// at path. See package description for details.
+func New(path, prefix string, opts ...Option) sources.Provider {
+	ret := &dotEnvProvider{fileprovider.Provider{
+		Path: path,
+		Parse: func(path string, data []byte, paramIDs sources.Parameters) (types.ParamValues, error) {
+			return parse(path, data, prefix+"__", paramIDs)
+		},
+	}}
+
+	for _, o := range opts {
+		o(ret)
+	}
+
+	return ret
+}
+
+// Option specifies options for the provider.
+type Option func(*dotEnvProvider)
+
+// WithPollInterval enables watching the file for changes, checking it with
+// the given interval. By default, the file is only read again when the
+// configuration is reloaded.
+func WithPollInterval(interval time.Duration) Option {
+	return func(p *dotEnvProvider) {
+		p.PollInterval = interval
+	}
+}
+
+type dotEnvProvider struct {
+	fileprovider.Provider
+}
+
+var (
+	_ sources.Reloader       = &dotEnvProvider{}
+	_ sources.ContextStopper = &dotEnvProvider{}
+)
+
+func parse(
+	path string,
+	data []byte,
+	prefix string,
+	paramIDs sources.Parameters,
+) (types.ParamValues, error) {
+	vars, err := parseFile(path, data)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string, len(vars))
+	for name, v := range vars {
+		values[name] = v.value
+	}
+
+	ret, unknown := envvar.Match(prefix, values, paramIDs)
+
+	violations := make(types.ErrViolations, 0, len(unknown))
+	for _, name := range unknown {
+		violations = append(violations, types.Violation{
+			Message: fmt.Sprintf(
+				"%s:%d: variable %q has the %q prefix, but it does not match any expected application parameter",
+				path, vars[name].line, name, prefix),
+		})
+	}
+
+	if len(violations) > 0 {
+		return ret, violations
+	}
+
+	return ret, nil
+}
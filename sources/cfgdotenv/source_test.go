@@ -0,0 +1,137 @@
+//go:build unittest || !integrationtest
+// +build unittest !integrationtest
+
+package cfgdotenv_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/simplesurance/proteus"
+	"github.com/simplesurance/proteus/internal/assert"
+	"github.com/simplesurance/proteus/internal/testfile"
+	"github.com/simplesurance/proteus/sources/cfgdotenv"
+	"github.com/simplesurance/proteus/types"
+	"github.com/simplesurance/proteus/xtypes"
+)
+
+func TestDotEnv(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	testfile.Write(t, path, `# development configuration
+CFG__NAME=app # comment
+export CFG__LEVEL=1
+OTHER_TOOL=ignored
+CFG__HTTP__ADDRESS = ":8080\t\"x\""
+CFG__HTTP__BANNER='single \n $quoted'
+CFG__HTTP__CERT="line 1
+line 2 \
+continued"
+CFG__HTTP__EMPTY=
+`)
+
+	params := struct {
+		Name  string
+		Level *xtypes.Integer[int]
+		HTTP  struct {
+			Address string
+			Banner  string
+			Cert    string
+			Empty   string
+		} `param:"http"`
+	}{}
+
+	parsed, err := proteus.MustParse(&params,
+		proteus.WithProviders(cfgdotenv.New(path, "cfg",
+			cfgdotenv.WithPollInterval(10*time.Millisecond))))
+	assert.NoErrorNow(t, err)
+	defer func() {
+		assert.NoError(t, parsed.Stop(context.Background()))
+	}()
+
+	assert.Equal(t, "app", params.Name)
+	assert.Equal(t, 1, params.Level.Value())
+	assert.Equal(t, ":8080\t\"x\"", params.HTTP.Address)
+	assert.Equal(t, `single \n $quoted`, params.HTTP.Banner)
+	assert.Equal(t, "line 1\nline 2 continued", params.HTTP.Cert)
+	assert.Equal(t, "", params.HTTP.Empty)
+
+	// changes are applied to xtypes
+	testfile.Write(t, path, `CFG__NAME=app
+CFG__LEVEL=2
+CFG__HTTP__ADDRESS=:8080
+CFG__HTTP__BANNER=
+CFG__HTTP__CERT=
+CFG__HTTP__EMPTY=
+`)
+
+	assert.Eventually(t, 2*time.Second, func() bool { return params.Level.Value() == 2 }, "value must be updated")
+
+	// invalid changes are ignored
+	testfile.Write(t, path, `CFG__LEVEL=3
+CFG__LEVLE=3
+`)
+	assert.Eventually(t, 2*time.Second, func() bool {
+		return !parsed.Providers()[0].Healthy()
+	}, "provider must report the error")
+	assert.Equal(t, 2, params.Level.Value())
+}
+
+func TestDotEnvUnknownVariables(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	testfile.Write(t, path, `CFG__NAME=app
+CFG__NMAE=typo
+CFG__HTTP__ADRESS=:80
+`)
+
+	params := struct {
+		Name string
+		HTTP struct {
+			Address string `param:",optional"`
+		} `param:"http"`
+	}{}
+
+	parsed, err := proteus.MustParse(&params,
+		proteus.WithProviders(cfgdotenv.New(path, "cfg")))
+	assert.ErrorNow(t, err)
+	defer parsed.Stop(context.Background()) //nolint:errcheck
+
+	var violations types.ErrViolations
+	assert.TrueNow(t, errors.As(err, &violations), "error must be violations")
+	assert.Equal(t, 2, len(violations))
+	assert.StringContains(t, err.Error(), path+":2:")
+	assert.StringContains(t, err.Error(), path+":3:")
+}
+
+func TestDotEnvSyntaxError(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		location string
+	}{
+		{"missing equal sign", "CFG__NAME=a\nCFG__LEVEL\n", ":2:"},
+		{"invalid name", "\nCFG-NAME=a\n", ":2:"},
+		{"unterminated quote", "CFG__NAME=a\nCFG__LEVEL=\"1\n\n", ":2:"},
+		{"text after quote", "CFG__NAME='a\nb' c\n", ":2:"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), ".env")
+			testfile.Write(t, path, tc.content)
+
+			params := struct {
+				Name string `param:",optional"`
+			}{}
+
+			parsed, err := proteus.MustParse(&params,
+				proteus.WithProviders(cfgdotenv.New(path, "cfg")))
+			assert.ErrorNow(t, err)
+			defer parsed.Stop(context.Background()) //nolint:errcheck
+
+			assert.StringContains(t, err.Error(), path+tc.location)
+		})
+	}
+}
@@ -24,70 +24,296 @@
 // Note that both "-" and "_" are mapped to "_". For this reason, if one
 // application has two parameters that are differentiated only by this
 // character, it can't be configured using this configuration provider.
+//
+// With the WithFileSuffix option, the value can also be read from a file,
+// by setting the variable with the "_FILE" suffix to its path, as done by
+// Docker secrets. For example:
+//
+//	CFG__DB__PWD_FILE=/run/secrets/db_pwd
+//
+// Setting both variables of a parameter is an error. One trailing line
+// break is removed from the content of the file, except for secrets, that
+// are used exactly as stored.
+//
+// When secrets are scrubbed (see proteus.WithSecretScrubbing), the variable
+// that provided each secret is unset, including the variable with the
+// "_FILE" suffix. The file itself is not removed.
 package cfgenv
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"slices"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/simplesurance/proteus/internal/envvar"
+	"github.com/simplesurance/proteus/internal/filepoll"
+	"github.com/simplesurance/proteus/plog"
 	"github.com/simplesurance/proteus/sources"
 	"github.com/simplesurance/proteus/types"
 )
 
+// fileSuffix is the suffix of variables with the path of a file that has
+// the value of a parameter.
+const fileSuffix = "_FILE"
+
 // New creates a new provider that allows configuring parameters using
 // environment variables. See package description for details.
-func New(prefix string) sources.Provider {
-	return &envVarProvider{prefix: prefix}
+func New(prefix string, opts ...Option) sources.Provider {
+	ret := &envVarProvider{prefix: prefix}
+	for _, o := range opts {
+		o(ret)
+	}
+
+	return ret
+}
+
+// Option specifies options for the provider.
+type Option func(*envVarProvider)
+
+// WithFileSuffix allows reading values from files referenced by variables
+// with the "_FILE" suffix. See package description for details.
+func WithFileSuffix() Option {
+	return func(p *envVarProvider) {
+		p.fileSuffix = true
+	}
+}
+
+// WithFilePollInterval makes the provider watch the files referenced by
+// variables with the "_FILE" suffix, updating the parameters when they
+// change, for example, when a secret is rotated. The files are checked with
+// the given interval. Only has effect with WithFileSuffix.
+func WithFilePollInterval(interval time.Duration) Option {
+	return func(p *envVarProvider) {
+		p.filePollInterval = interval
+	}
+}
+
+// scrubbedVar is a variable removed from the environment with Scrub.
+type scrubbedVar struct {
+	name  string
+	value string
 }
 
 type envVarProvider struct {
-	prefix string
+	prefix           string
+	fileSuffix       bool
+	filePollInterval time.Duration
+
+	paramIDs sources.Parameters
+	updater  sources.Updater
+
+	// mutex serializes reading the variables, which can be requested by
+	// the watchers of the files
+	mutex sync.Mutex
+
+	// scrubbed are the variables removed from the environment with Scrub,
+	// by the name of the variable without the "_FILE" suffix
+	scrubbed map[string]scrubbedVar
+
+	// watchers are the watchers of the files referenced by the variables,
+	// by path; retired are the ones of files not referenced anymore
+	watchers map[string]*filepoll.Watcher
+	retired  []*filepoll.Watcher
+	stopped  bool
 }
 
+var (
+	_ sources.Reloader       = &envVarProvider{}
+	_ sources.Scrubber       = &envVarProvider{}
+	_ sources.ContextStopper = &envVarProvider{}
+)
+
 func (r *envVarProvider) IsCommandLineFlag() bool {
 	return false
 }
 
 func (r *envVarProvider) Stop() {
+	_ = r.StopContext(context.Background())
+}
+
+// StopContext stops watching the files referenced by the variables.
+func (r *envVarProvider) StopContext(ctx context.Context) error {
+	r.mutex.Lock()
+	r.stopped = true
+	watchers := r.retired
+	for _, w := range r.watchers {
+		watchers = append(watchers, w)
+	}
+	r.mutex.Unlock()
+
+	var errs []error
+	for _, w := range watchers {
+		errs = append(errs, w.Stop(ctx))
+	}
+
+	return errors.Join(errs...)
 }
 
 func (r *envVarProvider) Watch(
 	paramIDs sources.Parameters,
-	_ sources.Updater,
+	updater sources.Updater,
 ) (initial types.ParamValues, _ error) {
-	return parse(r.prefix+"__", paramIDs)
+	r.paramIDs = paramIDs
+	r.updater = updater
+	return r.read()
 }
 
-func parse(
-	prefix string,
-	paramIDs sources.Parameters,
-) (types.ParamValues, error) {
-	env := readEnvVarsWithPrefix(prefix)
+// Reload reads the environment variables again. Environment variables of a
+// process usually do not change, but the application may change them, for
+// example, after reading them from a file.
+func (r *envVarProvider) Reload(_ context.Context) (types.ParamValues, error) {
+	return r.read()
+}
+
+func (r *envVarProvider) read() (types.ParamValues, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	ret, files, err := parse(r.prefix+"__", r.paramIDs, r.fileSuffix, r.scrubbed)
+
+	if r.filePollInterval > 0 && !r.stopped {
+		r.watchFiles(files)
+	}
+
+	return ret, err
+}
+
+// watchFiles starts watching the files that were not watched yet, from the
+// content that was read from them, and stops watching the ones not
+// referenced anymore. Caller must hold the mutex.
+func (r *envVarProvider) watchFiles(files map[string]*filepoll.Content) {
+	current := make(map[string]*filepoll.Watcher, len(files))
+	for path, content := range files {
+		if w, ok := r.watchers[path]; ok {
+			current[path] = w
+			delete(r.watchers, path)
+			continue
+		}
+
+		if content == nil {
+			// the error was already reported while reading the variables
+			continue
+		}
+
+		w := filepoll.New(path, r.filePollInterval)
+		w.SetContent(*content)
+		w.Start(r.fileChanged, r.fileError)
+		current[path] = w
+	}
+
+	// the remaining watchers are of files that are not referenced anymore;
+	// the watchers may be the ones calling this method, so they are only
+	// waited for when the provider is stopped
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, w := range r.watchers {
+		_ = w.Stop(canceled)
+		r.retired = append(r.retired, w)
+	}
+
+	// forget the retired watchers that already terminated
+	r.retired = slices.DeleteFunc(r.retired, (*filepoll.Watcher).Stopped)
+
+	r.watchers = current
+}
+
+func (r *envVarProvider) fileChanged(_ []byte) {
+	logger := plog.Logger(r.updater.Log)
+
+	values, err := r.read()
+	if err != nil {
+		logger.E(fmt.Sprintf("Ignoring changes on files referenced by environment variables: %v", err))
+		r.updater.ReportError(err)
+		return
+	}
 
-	ret := types.ParamValues{}
+	logger.I("File referenced by environment variable changed, updating parameters")
+	r.updater.Update(values)
+}
+
+func (r *envVarProvider) fileError(err error) {
+	plog.Logger(r.updater.Log).E(fmt.Sprintf(
+		"Watching file referenced by environment variable: %v", err))
+	r.updater.ReportError(err)
+}
+
+// Scrub removes the environment variables that provide the specified
+// parameters, including the ones with the "_FILE" suffix.
+func (r *envVarProvider) Scrub(paramIDs sources.Parameters) ([]sources.ScrubbedValue, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var ret []sources.ScrubbedValue
 	for setName, set := range paramIDs {
 		for paramName := range set {
-			envName := envVarName(setName, paramName, prefix)
-			value, ok := env[envName]
-			if !ok {
-				continue
+			envName := envvar.Name(setName, paramName, r.prefix+"__")
+
+			names := []string{envName}
+			if r.fileSuffix {
+				names = append(names, envName+fileSuffix)
 			}
 
-			set, ok := ret[setName]
-			if !ok {
-				set = map[string]string{}
-				ret[setName] = set
+			for _, name := range names {
+				value, ok := os.LookupEnv(name)
+				if !ok {
+					continue
+				}
+
+				if err := os.Unsetenv(name); err != nil {
+					return ret, fmt.Errorf("unsetting %s: %w", name, err)
+				}
+
+				if r.scrubbed == nil {
+					r.scrubbed = map[string]scrubbedVar{}
+				}
+
+				r.scrubbed[envName] = scrubbedVar{name: name, value: value}
+				ret = append(ret, sources.ScrubbedValue{
+					SetName:   setName,
+					ParamName: paramName,
+					Location:  name,
+				})
 			}
+		}
+	}
 
-			set[paramName] = value
+	return ret, nil
+}
 
-			delete(env, envName)
+// parse reads the variables with the prefix, returning the values and the
+// content of the files from where values were read, by path; the content is
+// nil for files that could not be read. Scrubbed variables are used as if
+// they were still set, unless the application set one of the variables of
+// the parameter again.
+func parse(
+	prefix string,
+	paramIDs sources.Parameters,
+	withFiles bool,
+	scrubbed map[string]scrubbedVar,
+) (types.ParamValues, map[string]*filepoll.Content, error) {
+	env := readEnvVarsWithPrefix(prefix)
+	for envName, v := range scrubbed {
+		_, isSet := env[envName]
+		_, isFileSet := env[envName+fileSuffix]
+		if !isSet && !isFileSet {
+			env[v.name] = v.value
 		}
 	}
 
-	violations := make(types.ErrViolations, 0, len(env))
-	for envName := range env {
+	var violations types.ErrViolations
+	var files map[string]*filepoll.Content
+	if withFiles {
+		violations, files = readFiles(prefix, env, paramIDs)
+	}
+
+	ret, unknown := envvar.Match(prefix, env, paramIDs)
+
+	for _, envName := range unknown {
 		violations = append(violations, types.Violation{
 			Message: fmt.Sprintf(
 				"Environment variable %q has the %q prefix, but is does not match any expected application parameter",
@@ -96,10 +322,66 @@ func parse(
 	}
 
 	if len(violations) > 0 {
-		return ret, violations
+		return ret, files, violations
 	}
 
-	return ret, nil
+	return ret, files, nil
+}
+
+// readFiles replaces on env the variables with the "_FILE" suffix by
+// variables with the content of the files they reference.
+func readFiles(
+	prefix string,
+	env map[string]string,
+	paramIDs sources.Parameters,
+) (violations types.ErrViolations, files map[string]*filepoll.Content) {
+	files = map[string]*filepoll.Content{}
+	for setName, set := range paramIDs {
+		for paramName, info := range set {
+			envName := envvar.Name(setName, paramName, prefix)
+			path, ok := env[envName+fileSuffix]
+			if !ok {
+				continue
+			}
+
+			delete(env, envName+fileSuffix)
+			if _, ok := env[envName]; ok {
+				violations = append(violations, types.Violation{
+					SetName:   setName,
+					ParamName: paramName,
+					Message: fmt.Sprintf(
+						"Environment variables %q and %q are both set, only one is allowed",
+						envName, envName+fileSuffix),
+				})
+				continue
+			}
+
+			content, err := filepoll.ReadFile(path)
+			files[path] = &content
+			if err != nil {
+				files[path] = nil
+				violations = append(violations, types.Violation{
+					SetName:   setName,
+					ParamName: paramName,
+					Message: fmt.Sprintf(
+						"Reading file referenced by %q: %v",
+						envName+fileSuffix, err),
+				})
+				continue
+			}
+
+			value := string(content.Data)
+			if !info.IsSecret {
+				if v, ok := strings.CutSuffix(value, "\n"); ok {
+					value = strings.TrimSuffix(v, "\r")
+				}
+			}
+
+			env[envName] = value
+		}
+	}
+
+	return violations, files
 }
 
 func readEnvVarsWithPrefix(prefix string) map[string]string {
@@ -117,16 +399,3 @@ func readEnvVarsWithPrefix(prefix string) map[string]string {
 
 	return ret
 }
-
-// envVarName produces the name of the environment variable that should be used
-// to configure a parameter. This function is not reversible.
-func envVarName(setName, valueName, prefix string) string {
-	var ret string
-	if setName == "" {
-		ret = prefix + valueName
-	} else {
-		ret = prefix + setName + "__" + valueName
-	}
-
-	return strings.ToUpper(strings.ReplaceAll(ret, "-", "_"))
-}
@@ -4,11 +4,14 @@
 package cfgenv_test
 
 import (
+	"context"
 	"encoding/json"
 	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/simplesurance/proteus/internal/assert"
 	"github.com/simplesurance/proteus/plog"
@@ -119,6 +122,11 @@ func (t *testUpdater) Update(p types.ParamValues) {
 	t.UpdateFn(p)
 }
 
+func (t *testUpdater) UpdateWithResult(p types.ParamValues) error {
+	t.UpdateFn(p)
+	return nil
+}
+
 func (t *testUpdater) Log(entry plog.Entry) {
 	t.LogFn(entry)
 }
@@ -127,3 +135,144 @@ func (*testUpdater) Peek(_, _ string) (*string, error) {
 	// environment variables do not read values from another providers
 	return nil, nil
 }
+
+func (*testUpdater) ReportError(_ error) {
+}
+
+func (*testUpdater) ReportHealthy() {
+}
+
+func TestScrub(t *testing.T) {
+	t.Setenv("TEST__TOKEN", "secret")
+	t.Setenv("TEST__NAME", "name")
+
+	paramIDs := sources.Parameters{
+		"": map[string]sources.ParameterInfo{"token": {}, "name": {}},
+	}
+
+	paramSource := cfgenv.New("TEST")
+	_, err := paramSource.Watch(paramIDs, &testUpdater{LogFn: plog.TestLogger(t)})
+	assert.NoErrorNow(t, err)
+
+	scrubbed, err := paramSource.(sources.Scrubber).Scrub(sources.Parameters{
+		"": map[string]sources.ParameterInfo{"token": {}},
+	})
+	assert.NoErrorNow(t, err)
+	assert.EqualNow(t, 1, len(scrubbed))
+	assert.Equal(t, "TEST__TOKEN", scrubbed[0].Location)
+
+	_, ok := os.LookupEnv("TEST__TOKEN")
+	assert.True(t, !ok, "variable must be unset")
+
+	values, err := paramSource.(sources.Reloader).Reload(context.Background())
+	assert.NoErrorNow(t, err)
+	assert.Equal(t, "secret", *values.Get("", "token"))
+	assert.Equal(t, "name", *values.Get("", "name"))
+}
+
+func TestFileSuffix(t *testing.T) {
+	dir := t.TempDir()
+	pwdPath := filepath.Join(dir, "pwd")
+	userPath := filepath.Join(dir, "user")
+	assert.NoErrorNow(t, os.WriteFile(pwdPath, []byte("s3cr3t\n"), 0o600))
+	assert.NoErrorNow(t, os.WriteFile(userPath, []byte("admin\n"), 0o600))
+
+	t.Setenv("TEST__DB__PWD_FILE", pwdPath)
+	t.Setenv("TEST__DB__USER_FILE", userPath)
+	t.Setenv("TEST__DB__NAME", "library")
+
+	paramIDs := sources.Parameters{
+		"db": map[string]sources.ParameterInfo{
+			"pwd":  {IsSecret: true},
+			"user": {},
+			"name": {},
+		},
+	}
+
+	// without the option, the variables are unknown
+	paramSource := cfgenv.New("TEST")
+	_, err := paramSource.Watch(paramIDs, &testUpdater{LogFn: plog.TestLogger(t)})
+	assert.ErrorNow(t, err)
+
+	paramSource = cfgenv.New("TEST", cfgenv.WithFileSuffix())
+	values, err := paramSource.Watch(paramIDs, &testUpdater{LogFn: plog.TestLogger(t)})
+	assert.NoErrorNow(t, err)
+	defer paramSource.Stop()
+
+	// secrets are used exactly as stored
+	assert.Equal(t, "s3cr3t\n", *values.Get("db", "pwd"))
+	assert.Equal(t, "admin", *values.Get("db", "user"))
+	assert.Equal(t, "library", *values.Get("db", "name"))
+
+	// both forms can't be used at the same time
+	t.Setenv("TEST__DB__PWD", "other")
+	_, err = paramSource.(sources.Reloader).Reload(context.Background())
+	assert.ErrorNow(t, err)
+	assert.StringContains(t, err.Error(), "TEST__DB__PWD_FILE")
+	assert.True(t, !strings.Contains(err.Error(), "s3cr3t"), "secret must not be on the error")
+}
+
+func TestScrubFileSuffix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token")
+	assert.NoErrorNow(t, os.WriteFile(path, []byte("secret"), 0o600))
+	t.Setenv("TEST__TOKEN_FILE", path)
+
+	paramIDs := sources.Parameters{
+		"": map[string]sources.ParameterInfo{"token": {IsSecret: true}},
+	}
+
+	paramSource := cfgenv.New("TEST", cfgenv.WithFileSuffix())
+	_, err := paramSource.Watch(paramIDs, &testUpdater{LogFn: plog.TestLogger(t)})
+	assert.NoErrorNow(t, err)
+	defer paramSource.Stop()
+
+	scrubbed, err := paramSource.(sources.Scrubber).Scrub(paramIDs)
+	assert.NoErrorNow(t, err)
+	assert.EqualNow(t, 1, len(scrubbed))
+	assert.Equal(t, "TEST__TOKEN_FILE", scrubbed[0].Location)
+
+	_, ok := os.LookupEnv("TEST__TOKEN_FILE")
+	assert.True(t, !ok, "variable must be unset")
+
+	// the value is still read from the file
+	assert.NoErrorNow(t, os.WriteFile(path, []byte("rotated"), 0o600))
+	values, err := paramSource.(sources.Reloader).Reload(context.Background())
+	assert.NoErrorNow(t, err)
+	assert.Equal(t, "rotated", *values.Get("", "token"))
+}
+
+func TestFileSuffixWatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pwd")
+	assert.NoErrorNow(t, os.WriteFile(path, []byte("v1"), 0o600))
+	t.Setenv("TEST__PWD_FILE", path)
+
+	updates := make(chan types.ParamValues, 10)
+	paramSource := cfgenv.New("TEST",
+		cfgenv.WithFileSuffix(),
+		cfgenv.WithFilePollInterval(10*time.Millisecond))
+	values, err := paramSource.Watch(sources.Parameters{
+		"": map[string]sources.ParameterInfo{"pwd": {IsSecret: true}},
+	}, &testUpdater{
+		LogFn:    plog.TestLogger(t),
+		UpdateFn: func(v types.ParamValues) { updates <- v },
+	})
+	assert.NoErrorNow(t, err)
+	defer func() {
+		assert.NoError(t, paramSource.(sources.ContextStopper).StopContext(context.Background()))
+	}()
+
+	assert.Equal(t, "v1", *values.Get("", "pwd"))
+
+	// make sure that the modification time changes even on file systems
+	// with low resolution timestamps
+	assert.NoErrorNow(t, os.WriteFile(path, []byte("v2"), 0o600))
+	modTime := time.Now().Add(time.Second)
+	assert.NoErrorNow(t, os.Chtimes(path, modTime, modTime))
+
+	select {
+	case values := <-updates:
+		assert.Equal(t, "v2", *values.Get("", "pwd"))
+	case <-time.After(2 * time.Second):
+		t.Fatalf("timeout waiting for update")
+	}
+}
@@ -0,0 +1,442 @@
+// Package cfgexec is a parameter provider that reads values from the
+// output of external commands, like the command-line tools of password
+// managers and secret stores.
+//
+// There are two ways of using it. With NewPerParam, one command is run for
+// each parameter, and its standard output, without the trailing line
+// break, is the value of the parameter:
+//
+//	cfgexec.NewPerParam([]cfgexec.ParamCommand{
+//		{SetName: "db", ParamName: "pwd", Command: cfgexec.Command{
+//			Path: "pass", Args: []string{"show", "db/pwd"},
+//		}},
+//	})
+//
+// With New, a single command provides all values, printing them either as
+// a JSON object, in the same format read by cfgfile, or as "key=value"
+// lines, where the key is "param" for parameters that are not on a set and
+// "set.param" for parameters on a set. Empty lines and lines starting with
+// "#" are ignored.
+//
+// Commands are run with a timeout, and the standard error of failed
+// commands is included in the returned errors. The standard output is
+// never logged or included in errors, so values of secrets can't leak.
+// Optionally, the commands can be run periodically to refresh the values,
+// see WithRefreshInterval.
+package cfgexec
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/simplesurance/proteus/internal/jsonparams"
+	"github.com/simplesurance/proteus/internal/paramvalues"
+	"github.com/simplesurance/proteus/plog"
+	"github.com/simplesurance/proteus/sources"
+	"github.com/simplesurance/proteus/types"
+)
+
+// DefaultTimeout is how long commands can run, if not specified with
+// WithTimeout.
+const DefaultTimeout = 30 * time.Second
+
+// maxStderr is how much of the standard error is kept to be included in
+// errors.
+const maxStderr = 4096
+
+// Command is a command to be run.
+type Command struct {
+	// Path is the name of the program; if it does not contain path
+	// separators, it is searched for on the PATH.
+	Path string
+
+	// Args are the arguments passed to the program.
+	Args []string
+}
+
+// ParamCommand is a command that prints the value of a parameter.
+type ParamCommand struct {
+	SetName   string
+	ParamName string
+	Command   Command
+}
+
+// Format is the format of the output of a command that prints the values
+// of multiple parameters.
+type Format int
+
+const (
+	// FormatJSON is a JSON object, as read by cfgfile.
+	FormatJSON Format = iota
+
+	// FormatKeyValue are "key=value" lines.
+	FormatKeyValue
+)
+
+// New creates a provider that runs a single command that prints the values
+// of the parameters in the specified format. See package description for
+// details.
+func New(cmd Command, format Format, opts ...Option) sources.Provider {
+	ret := newProvider(opts)
+	ret.command = &cmd
+	ret.format = format
+	return ret
+}
+
+// NewPerParam creates a provider that runs one command for each parameter.
+// See package description for details.
+func NewPerParam(commands []ParamCommand, opts ...Option) sources.Provider {
+	ret := newProvider(opts)
+	ret.paramCommands = commands
+	return ret
+}
+
+// Option specifies options for the provider.
+type Option func(*execProvider)
+
+// WithTimeout specifies how long each command can run.
+func WithTimeout(timeout time.Duration) Option {
+	return func(p *execProvider) {
+		p.timeout = timeout
+	}
+}
+
+// WithRefreshInterval makes the provider run the commands again with the
+// given interval, updating the parameters when their values change. By
+// default, the commands only run on startup and when the configuration is
+// reloaded.
+func WithRefreshInterval(interval time.Duration) Option {
+	return func(p *execProvider) {
+		p.refreshInterval = interval
+	}
+}
+
+func newProvider(opts []Option) *execProvider {
+	ret := &execProvider{
+		timeout: DefaultTimeout,
+		done:    make(chan struct{}),
+	}
+
+	ret.ctx, ret.cancel = context.WithCancel(context.Background())
+
+	for _, o := range opts {
+		o(ret)
+	}
+
+	return ret
+}
+
+type execProvider struct {
+	command       *Command
+	format        Format
+	paramCommands []ParamCommand
+
+	timeout         time.Duration
+	refreshInterval time.Duration
+
+	paramIDs sources.Parameters
+	updater  sources.Updater
+
+	// mutex serializes running the commands, and protects the last values
+	mutex  sync.Mutex
+	values types.ParamValues
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	started atomic.Bool
+	done    chan struct{}
+}
+
+var (
+	_ sources.ContextWatcher = &execProvider{}
+	_ sources.ContextStopper = &execProvider{}
+	_ sources.Reloader       = &execProvider{}
+)
+
+func (r *execProvider) IsCommandLineFlag() bool {
+	return false
+}
+
+func (r *execProvider) Stop() {
+	_ = r.StopContext(context.Background())
+}
+
+// StopContext stops refreshing the values, killing commands that are
+// running.
+func (r *execProvider) StopContext(ctx context.Context) error {
+	r.cancel()
+
+	if !r.started.Load() {
+		return nil
+	}
+
+	select {
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *execProvider) Watch(
+	paramIDs sources.Parameters,
+	updater sources.Updater,
+) (initial types.ParamValues, _ error) {
+	return r.WatchContext(context.Background(), paramIDs, updater)
+}
+
+// WatchContext runs the commands, killing them if ctx is done.
+func (r *execProvider) WatchContext(
+	ctx context.Context,
+	paramIDs sources.Parameters,
+	updater sources.Updater,
+) (initial types.ParamValues, _ error) {
+	r.paramIDs = paramIDs
+	r.updater = updater
+
+	initial, _, err := r.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.refreshInterval <= 0 {
+		return initial, nil
+	}
+
+	r.started.Store(true)
+	go r.refresh()
+
+	return initial, nil
+}
+
+// Reload runs the commands again.
+func (r *execProvider) Reload(ctx context.Context) (types.ParamValues, error) {
+	values, _, err := r.read(ctx)
+	return values, err
+}
+
+func (r *execProvider) refresh() {
+	defer close(r.done)
+
+	logger := plog.Logger(r.updater.Log)
+
+	ticker := time.NewTicker(r.refreshInterval)
+	defer ticker.Stop()
+
+	failed := false
+	for {
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		values, changed, err := r.read(r.ctx)
+		if err != nil {
+			if r.ctx.Err() != nil {
+				return
+			}
+
+			failed = true
+			logger.E(fmt.Sprintf("Refreshing values: %v", err))
+			r.updater.ReportError(err)
+			continue
+		}
+
+		recovered := failed
+		failed = false
+
+		switch {
+		case changed:
+			logger.I("Values changed, updating parameters")
+			r.updater.Update(values)
+		case recovered:
+			r.updater.ReportHealthy()
+		}
+	}
+}
+
+// read runs the commands, returning the values and whether they changed
+// since the last time the commands were run.
+func (r *execProvider) read(ctx context.Context) (types.ParamValues, bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var values types.ParamValues
+	var err error
+	if r.command != nil {
+		values, err = r.runCommand(ctx)
+	} else {
+		values, err = r.runParamCommands(ctx)
+	}
+
+	if err != nil {
+		return values, false, err
+	}
+
+	changed := !maps.EqualFunc(values, r.values, maps.Equal)
+	r.values = values
+
+	return values.Copy(), changed, nil
+}
+
+func (r *execProvider) runParamCommands(ctx context.Context) (types.ParamValues, error) {
+	ret := types.ParamValues{}
+	var violations types.ErrViolations
+	var errs []error
+	for _, pc := range r.paramCommands {
+		if _, ok := r.paramIDs.Get(pc.SetName, pc.ParamName); !ok {
+			violations = append(violations, types.Violation{
+				Message: fmt.Sprintf(
+					"Command %q is configured for parameter %q of set %q, that is not an expected application parameter",
+					pc.Command.Path, pc.ParamName, pc.SetName),
+			})
+			continue
+		}
+
+		out, err := r.run(ctx, pc.Command)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("parameter %q of set %q: %w",
+				pc.ParamName, pc.SetName, err))
+			continue
+		}
+
+		value := string(out)
+		if v, ok := strings.CutSuffix(value, "\n"); ok {
+			value = strings.TrimSuffix(v, "\r")
+		}
+
+		paramvalues.Set(ret, pc.SetName, pc.ParamName, value)
+	}
+
+	if len(violations) > 0 {
+		errs = append(errs, violations)
+	}
+
+	return ret, errors.Join(errs...)
+}
+
+func (r *execProvider) runCommand(ctx context.Context) (types.ParamValues, error) {
+	out, err := r.run(ctx, *r.command)
+	if err != nil {
+		return nil, err
+	}
+
+	source := fmt.Sprintf("output of %q", r.command.Path)
+	switch r.format {
+	case FormatJSON:
+		// errors from the JSON parser can include parts of the output
+		if !json.Valid(out) {
+			return nil, fmt.Errorf("%s is not valid JSON", source)
+		}
+
+		return jsonparams.Parse(source, out, r.paramIDs)
+	case FormatKeyValue:
+		return parseKeyValue(source, out, r.paramIDs)
+	default:
+		return nil, fmt.Errorf("unsupported output format %d", r.format)
+	}
+}
+
+// run runs the command, returning its standard output.
+func (r *execProvider) run(ctx context.Context, command Command) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var stdout bytes.Buffer
+	stderr := limitedBuffer{limit: maxStderr}
+
+	cmd := exec.CommandContext(ctx, command.Path, command.Args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	// do not wait forever for children that keep the output open
+	cmd.WaitDelay = time.Second
+
+	err := cmd.Run()
+	switch {
+	case err == nil:
+		return stdout.Bytes(), nil
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		err = fmt.Errorf("timed out after %v", r.timeout)
+	case ctx.Err() != nil:
+		err = ctx.Err()
+	}
+
+	if msg := strings.TrimSpace(stderr.String()); msg != "" {
+		return nil, fmt.Errorf("running %q: %w; stderr: %s", command.Path, err, msg)
+	}
+
+	return nil, fmt.Errorf("running %q: %w", command.Path, err)
+}
+
+// parseKeyValue parses "key=value" lines. Errors identify lines only by
+// their number, to avoid leaking values.
+func parseKeyValue(
+	source string,
+	out []byte,
+	paramIDs sources.Parameters,
+) (types.ParamValues, error) {
+	ret := types.ParamValues{}
+	var violations types.ErrViolations
+
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	scanner.Buffer(nil, len(out)+1)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("%s: line %d: expected key=value", source, lineNo)
+		}
+
+		setName, paramName, onSet := strings.Cut(key, ".")
+		if !onSet {
+			setName, paramName = "", key
+		}
+
+		if _, ok := paramIDs.Get(setName, paramName); !ok {
+			violations = append(violations, types.Violation{
+				Message: fmt.Sprintf(
+					"Key %q on %s does not match any expected application parameter",
+					key, source),
+			})
+			continue
+		}
+
+		paramvalues.Set(ret, setName, paramName, value)
+	}
+
+	if len(violations) > 0 {
+		return ret, violations
+	}
+
+	return ret, nil
+}
+
+// limitedBuffer keeps only the beginning of what is written to it.
+type limitedBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if remaining := b.limit - b.Len(); remaining > 0 {
+		b.Buffer.Write(p[:min(len(p), remaining)])
+	}
+
+	return len(p), nil
+}
@@ -0,0 +1,191 @@
+//go:build unittest || !integrationtest
+// +build unittest !integrationtest
+
+package cfgexec_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/simplesurance/proteus"
+	"github.com/simplesurance/proteus/internal/assert"
+	"github.com/simplesurance/proteus/plog"
+	"github.com/simplesurance/proteus/sources"
+	"github.com/simplesurance/proteus/sources/cfgexec"
+	"github.com/simplesurance/proteus/types"
+	"github.com/simplesurance/proteus/xtypes"
+)
+
+func TestPerParam(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "level")
+	assert.NoErrorNow(t, os.WriteFile(path, []byte("1\n"), 0o600))
+
+	params := struct {
+		Level *xtypes.Integer[int]
+		DB    struct {
+			Pwd string `param:",secret"`
+		} `param:"db"`
+	}{}
+
+	parsed, err := proteus.MustParse(&params,
+		proteus.WithProviders(cfgexec.NewPerParam([]cfgexec.ParamCommand{
+			{ParamName: "level", Command: shell("cat " + path)},
+			{SetName: "db", ParamName: "pwd", Command: shell("echo s3cr3t")},
+		}, cfgexec.WithRefreshInterval(10*time.Millisecond))))
+	assert.NoErrorNow(t, err)
+	defer func() {
+		assert.NoError(t, parsed.Stop(context.Background()))
+	}()
+
+	assert.Equal(t, 1, params.Level.Value())
+	assert.Equal(t, "s3cr3t", params.DB.Pwd)
+
+	assert.NoErrorNow(t, os.WriteFile(path, []byte("2\n"), 0o600))
+	assert.Eventually(t, 2*time.Second, func() bool { return params.Level.Value() == 2 }, "value must be updated")
+}
+
+func TestSingleCommand(t *testing.T) {
+	tests := []struct {
+		name   string
+		format cfgexec.Format
+		output string
+	}{
+		{"json", cfgexec.FormatJSON, `{"name": "app", "db": {"pwd": "s3cr3t"}}`},
+		{"key value", cfgexec.FormatKeyValue, "# comment\nname=app\n\ndb.pwd=s3cr3t\n"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			params := struct {
+				Name string
+				DB   struct {
+					Pwd string `param:",secret"`
+				} `param:"db"`
+			}{}
+
+			parsed, err := proteus.MustParse(&params,
+				proteus.WithProviders(cfgexec.New(
+					shell("printf '%s' '"+tc.output+"'"), tc.format)))
+			assert.NoErrorNow(t, err)
+			defer func() {
+				assert.NoError(t, parsed.Stop(context.Background()))
+			}()
+
+			assert.Equal(t, "app", params.Name)
+			assert.Equal(t, "s3cr3t", params.DB.Pwd)
+		})
+	}
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider sources.Provider
+		want     string
+	}{
+		{
+			name: "stderr is captured",
+			provider: cfgexec.NewPerParam([]cfgexec.ParamCommand{{
+				ParamName: "pwd",
+				Command:   shell("echo s3cr3t; echo 'no such item' >&2; exit 1"),
+			}}),
+			want: "no such item",
+		},
+		{
+			name: "timeout",
+			provider: cfgexec.NewPerParam([]cfgexec.ParamCommand{{
+				ParamName: "pwd",
+				Command:   shell("echo s3cr3t; sleep 5"),
+			}}, cfgexec.WithTimeout(100*time.Millisecond)),
+			want: "timed out",
+		},
+		{
+			name:     "invalid json",
+			provider: cfgexec.New(shell(`echo '{"pwd": s3cr3t}'`), cfgexec.FormatJSON),
+			want:     "not valid JSON",
+		},
+		{
+			name:     "invalid key value",
+			provider: cfgexec.New(shell("echo s3cr3t"), cfgexec.FormatKeyValue),
+			want:     "line 1",
+		},
+		{
+			name:     "unknown key",
+			provider: cfgexec.New(shell("echo pdw=s3cr3t"), cfgexec.FormatKeyValue),
+			want:     `"pdw"`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			logs := &logRecorder{}
+			params := struct {
+				Pwd string `param:",secret"`
+			}{}
+
+			parsed, err := proteus.MustParse(&params,
+				proteus.WithLogger(logs.log),
+				proteus.WithProviders(tc.provider))
+			assert.ErrorNow(t, err)
+			defer parsed.Stop(context.Background()) //nolint:errcheck
+
+			// output of the commands must never be shown
+			assert.StringContains(t, err.Error(), tc.want)
+			assert.True(t, !strings.Contains(err.Error(), "s3cr3t"),
+				"secret must not be on the error: "+err.Error())
+			assert.True(t, !logs.contains("s3cr3t"), "secret must not be logged")
+		})
+	}
+}
+
+func TestUnknownParam(t *testing.T) {
+	params := struct {
+		Name string `param:",optional"`
+	}{}
+
+	parsed, err := proteus.MustParse(&params,
+		proteus.WithProviders(cfgexec.NewPerParam([]cfgexec.ParamCommand{
+			{ParamName: "nmae", Command: shell("echo app")},
+		})))
+	assert.ErrorNow(t, err)
+	defer parsed.Stop(context.Background()) //nolint:errcheck
+
+	var violations types.ErrViolations
+	assert.TrueNow(t, errors.As(err, &violations), "error must be violations")
+	assert.StringContains(t, err.Error(), "nmae")
+}
+
+func shell(script string) cfgexec.Command {
+	return cfgexec.Command{Path: "sh", Args: []string{"-c", script}}
+}
+
+type logRecorder struct {
+	mutex   sync.Mutex
+	entries []plog.Entry
+}
+
+func (l *logRecorder) log(entry plog.Entry) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.entries = append(l.entries, entry)
+}
+
+func (l *logRecorder) contains(s string) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	for _, e := range l.entries {
+		if strings.Contains(e.Message, s) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,76 @@
+// Package cfgfile is a parameter provider that reads values from a JSON
+// file.
+//
+// The file must contain a JSON object. Its keys are the names of parameters
+// that are not on a set, or the names of parameter sets, whose values are
+// objects with the parameters of the set. For example:
+//
+//	{
+//		"log_level": "info",
+//		"http": {
+//			"address": ":8080",
+//			"max_connections": 64,
+//			"enabled": true
+//		}
+//	}
+//
+// Strings are used as they are, while numbers and booleans are used as they
+// are written in the file. Arrays and objects provided as the value of a
+// parameter are provided as JSON, allowing them to be used with
+// xtypes.RawJSON. A null value is handled as if the parameter was not
+// provided.
+//
+// Like cfgenv, all keys must match a parameter or a parameter set of the
+// application, allowing the detection of small mistakes like typos.
+//
+// The file is polled for changes, and parameters are updated when it
+// changes.
+package cfgfile
+
+import (
+	"time"
+
+	"github.com/simplesurance/proteus/internal/fileprovider"
+	"github.com/simplesurance/proteus/internal/jsonparams"
+	"github.com/simplesurance/proteus/sources"
+)
+
+// DefaultPollInterval is how often the file is checked for changes, if not
+// specified with WithPollInterval.
+const DefaultPollInterval = 5 * time.Second
+
+// New creates a new provider that reads parameters from the JSON file at
+// path. See package description for details.
+func New(path string, opts ...Option) sources.Provider {
+	ret := &fileProvider{fileprovider.Provider{
+		Path:         path,
+		PollInterval: DefaultPollInterval,
+		Parse:        jsonparams.Parse,
+	}}
+
+	for _, o := range opts {
+		o(ret)
+	}
+
+	return ret
+}
+
+// Option specifies options for the provider.
+type Option func(*fileProvider)
+
+// WithPollInterval specifies how often the file is checked for changes. If
+// not positive, the file is not watched for changes.
+func WithPollInterval(interval time.Duration) Option {
+	return func(p *fileProvider) {
+		p.PollInterval = interval
+	}
+}
+
+type fileProvider struct {
+	fileprovider.Provider
+}
+
+var (
+	_ sources.Reloader       = &fileProvider{}
+	_ sources.ContextStopper = &fileProvider{}
+)
@@ -0,0 +1,114 @@
+//go:build unittest || !integrationtest
+// +build unittest !integrationtest
+
+package cfgfile_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/simplesurance/proteus"
+	"github.com/simplesurance/proteus/internal/assert"
+	"github.com/simplesurance/proteus/internal/testfile"
+	"github.com/simplesurance/proteus/sources/cfgfile"
+	"github.com/simplesurance/proteus/types"
+	"github.com/simplesurance/proteus/xtypes"
+)
+
+func TestCfgFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	testfile.Write(t, path, `{
+		"name": "app",
+		"level": 1,
+		"extra": {"a": [1, 2]},
+		"http": {
+			"address": ":8080",
+			"enabled": true,
+			"timeout": null
+		}
+	}`)
+
+	params := struct {
+		Name  string
+		Level *xtypes.Integer[int]
+		Extra *xtypes.RawJSON
+		HTTP  struct {
+			Address string
+			Enabled bool
+			Timeout time.Duration `param:",optional"`
+		} `param:"http"`
+	}{}
+	params.HTTP.Timeout = time.Second
+
+	parsed, err := proteus.MustParse(&params,
+		proteus.WithProviders(cfgfile.New(path,
+			cfgfile.WithPollInterval(10*time.Millisecond))))
+	assert.NoErrorNow(t, err)
+	defer func() {
+		assert.NoError(t, parsed.Stop(context.Background()))
+	}()
+
+	assert.Equal(t, "app", params.Name)
+	assert.Equal(t, 1, params.Level.Value())
+	assert.Equal(t, `{"a": [1, 2]}`, string(params.Extra.Value()))
+	assert.Equal(t, ":8080", params.HTTP.Address)
+	assert.Equal(t, true, params.HTTP.Enabled)
+	assert.Equal(t, time.Second, params.HTTP.Timeout)
+
+	// changes are applied to xtypes
+	testfile.Write(t, path, `{
+		"name": "app",
+		"level": 2,
+		"extra": {},
+		"http": {"address": ":8080", "enabled": true}
+	}`)
+
+	assert.Eventually(t, 2*time.Second, func() bool { return params.Level.Value() == 2 }, "value must be updated")
+
+	// invalid changes are ignored
+	testfile.Write(t, path, `{"level": 3, "unknown": true}`)
+	assert.Eventually(t, 2*time.Second, func() bool {
+		return !parsed.Providers()[0].Healthy()
+	}, "provider must report the error")
+	assert.Equal(t, 2, params.Level.Value())
+}
+
+func TestCfgFileUnknownKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	testfile.Write(t, path, `{"name": "app", "nmae": "typo", "http": {"adress": ":80"}}`)
+
+	params := struct {
+		Name string
+		HTTP struct {
+			Address string `param:",optional"`
+		} `param:"http"`
+	}{}
+
+	parsed, err := proteus.MustParse(&params,
+		proteus.WithProviders(cfgfile.New(path, cfgfile.WithPollInterval(0))))
+	assert.ErrorNow(t, err)
+	defer parsed.Stop(context.Background()) //nolint:errcheck
+
+	var violations types.ErrViolations
+	assert.TrueNow(t, errors.As(err, &violations), "error must be violations")
+	assert.Equal(t, 2, len(violations))
+}
+
+func TestCfgFileInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	testfile.Write(t, path, `{"name": `)
+
+	params := struct {
+		Name string
+	}{}
+
+	parsed, err := proteus.MustParse(&params,
+		proteus.WithProviders(cfgfile.New(path)))
+	assert.ErrorNow(t, err)
+	defer parsed.Stop(context.Background()) //nolint:errcheck
+
+	assert.StringContains(t, err.Error(), path)
+}
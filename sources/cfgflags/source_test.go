@@ -126,6 +126,11 @@ func (t *testUpdater) Update(p types.ParamValues) {
 	t.UpdateFn(p)
 }
 
+func (t *testUpdater) UpdateWithResult(p types.ParamValues) error {
+	t.UpdateFn(p)
+	return nil
+}
+
 func (t *testUpdater) Log(entry plog.Entry) {
 	t.LogFn(entry)
 }
@@ -133,3 +138,9 @@ func (t *testUpdater) Log(entry plog.Entry) {
 func (t *testUpdater) Peek(_, _ string) (*string, error) {
 	return nil, nil // flags do not peek values from other provider
 }
+
+func (*testUpdater) ReportError(_ error) {
+}
+
+func (*testUpdater) ReportHealthy() {
+}
@@ -0,0 +1,443 @@
+// Package cfghttp is a parameter provider that periodically fetches values
+// from an HTTP endpoint.
+//
+// The endpoint must return a JSON object in the same format read by
+// cfgfile: its keys are the names of parameters that are not on a set, or
+// the names of parameter sets, whose values are objects with the parameters
+// of the set. All keys must match a parameter or a parameter set of the
+// application.
+//
+// The URL is read again before each request. It can be provided by an
+// xtypes.URL that is set before parsing, see New, or by a parameter read by
+// a provider registered before this one, see NewFromParam, allowing the
+// endpoint to be configured like any other parameter. In that case, the URL
+// is also fetched when the value of the parameter changes.
+//
+// Requests are conditional: the ETag and Last-Modified headers returned by
+// the server are sent back with If-None-Match and If-Modified-Since, so
+// servers that support them don't have to send the content if it did not
+// change. Updates are only sent to proteus when the content changes. When
+// requests fail, the interval between them grows exponentially, up to the
+// limit specified with WithMaxBackoff, and the error is reported on the
+// status of the provider. Responses larger than the limit specified with
+// WithMaxBodySize are rejected.
+package cfghttp
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/simplesurance/proteus/internal/jsonparams"
+	"github.com/simplesurance/proteus/plog"
+	"github.com/simplesurance/proteus/sources"
+	"github.com/simplesurance/proteus/types"
+	"github.com/simplesurance/proteus/xtypes"
+)
+
+const (
+	// DefaultPollInterval is how often the URL is fetched, if not
+	// specified with WithPollInterval.
+	DefaultPollInterval = 30 * time.Second
+
+	// DefaultMaxBackoff is the maximum interval between requests after
+	// errors, if not specified with WithMaxBackoff.
+	DefaultMaxBackoff = 5 * time.Minute
+
+	// DefaultTimeout is the timeout of the requests made with the default
+	// HTTP client.
+	DefaultTimeout = 10 * time.Second
+
+	// DefaultMaxBodySize is the maximum size of the responses, in bytes,
+	// if not specified with WithMaxBodySize.
+	DefaultMaxBodySize = 1 << 20
+)
+
+// New creates a new provider that fetches the parameters from the URL held
+// by u. See package description for details.
+//
+// The provider starts before the configuration struct is filled, so u must
+// already hold a URL when MustParse is called, usually on its DefaultValue.
+// An xtypes.URL declared on the same configuration struct is still empty at
+// that point; use NewFromParam to read the URL from a parameter.
+func New(u *xtypes.URL, opts ...Option) sources.Provider {
+	return newProvider(func(sources.Updater) (*url.URL, error) {
+		var ret *url.URL
+		if u != nil {
+			ret = u.Value()
+		}
+
+		if ret == nil {
+			return nil, errors.New("URL to fetch the configuration from is not set; " +
+				"URLs read from parameters must be provided with NewFromParam")
+		}
+
+		return ret, nil
+	}, opts)
+}
+
+// NewFromParam creates a new provider that fetches the parameters from the
+// URL provided as the value of a parameter by the providers registered
+// before this one. The parameter must be declared on the configuration
+// struct, like any other parameter. See package description for details.
+func NewFromParam(setName, paramName string, opts ...Option) sources.Provider {
+	ret := newProvider(func(updater sources.Updater) (*url.URL, error) {
+		value, err := updater.Peek(setName, paramName)
+		if err != nil {
+			return nil, err
+		}
+
+		if value == nil || *value == "" {
+			return nil, fmt.Errorf(
+				"URL to fetch the configuration from is not set on parameter %q of set %q",
+				paramName, setName)
+		}
+
+		ret, err := url.Parse(*value)
+		if err != nil {
+			return nil, fmt.Errorf("parameter %q of set %q: %w", paramName, setName, err)
+		}
+
+		return ret, nil
+	}, opts)
+
+	ret.upstreamChanged = make(chan struct{}, 1)
+	return ret
+}
+
+// Option specifies options for the provider.
+type Option func(*httpProvider)
+
+// WithPollInterval specifies how often the URL is fetched. If not
+// positive, the URL is only fetched on startup and when the configuration
+// is reloaded.
+func WithPollInterval(interval time.Duration) Option {
+	return func(p *httpProvider) {
+		p.pollInterval = interval
+	}
+}
+
+// WithMaxBackoff specifies the maximum interval between requests when they
+// fail.
+func WithMaxBackoff(maxBackoff time.Duration) Option {
+	return func(p *httpProvider) {
+		p.maxBackoff = maxBackoff
+	}
+}
+
+// WithHTTPClient specifies the client used to make the requests. It should
+// have a timeout.
+func WithHTTPClient(client *http.Client) Option {
+	return func(p *httpProvider) {
+		p.client = client
+	}
+}
+
+// WithMaxBodySize specifies the maximum size of the responses, in bytes.
+// Larger responses are rejected.
+func WithMaxBodySize(size int64) Option {
+	return func(p *httpProvider) {
+		p.maxBodySize = size
+	}
+}
+
+// WithHeader adds a header to all requests, for example, for
+// authentication.
+func WithHeader(name, value string) Option {
+	return func(p *httpProvider) {
+		p.header.Add(name, value)
+	}
+}
+
+func newProvider(
+	urlFn func(sources.Updater) (*url.URL, error),
+	opts []Option,
+) *httpProvider {
+	ret := &httpProvider{
+		urlFn:        urlFn,
+		pollInterval: DefaultPollInterval,
+		maxBackoff:   DefaultMaxBackoff,
+		maxBodySize:  DefaultMaxBodySize,
+		client:       &http.Client{Timeout: DefaultTimeout},
+		header:       http.Header{},
+		done:         make(chan struct{}),
+	}
+
+	ret.ctx, ret.cancel = context.WithCancel(context.Background())
+
+	for _, o := range opts {
+		o(ret)
+	}
+
+	return ret
+}
+
+type httpProvider struct {
+	urlFn        func(sources.Updater) (*url.URL, error)
+	pollInterval time.Duration
+	maxBackoff   time.Duration
+	maxBodySize  int64
+	client       *http.Client
+	header       http.Header
+
+	// upstreamChanged receives a value when the parameter with the URL
+	// may have changed; nil if the URL is not read from a parameter
+	upstreamChanged chan struct{}
+
+	paramIDs sources.Parameters
+	updater  sources.Updater
+
+	// mutex serializes the requests, and protects the state of the last
+	// request
+	mutex        sync.Mutex
+	url          string
+	etag         string
+	lastModified string
+	hash         [sha256.Size]byte
+	values       types.ParamValues
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	started atomic.Bool
+	done    chan struct{}
+}
+
+var (
+	_ sources.ContextWatcher   = &httpProvider{}
+	_ sources.ContextStopper   = &httpProvider{}
+	_ sources.Reloader         = &httpProvider{}
+	_ sources.UpstreamObserver = &httpProvider{}
+)
+
+func (r *httpProvider) IsCommandLineFlag() bool {
+	return false
+}
+
+func (r *httpProvider) Stop() {
+	_ = r.StopContext(context.Background())
+}
+
+// StopContext stops fetching the URL, canceling any in-flight request.
+func (r *httpProvider) StopContext(ctx context.Context) error {
+	r.cancel()
+
+	if !r.started.Load() {
+		return nil
+	}
+
+	select {
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *httpProvider) Watch(
+	paramIDs sources.Parameters,
+	updater sources.Updater,
+) (initial types.ParamValues, _ error) {
+	return r.WatchContext(context.Background(), paramIDs, updater)
+}
+
+// WatchContext fetches the URL, using ctx to limit how long to wait for
+// the response.
+func (r *httpProvider) WatchContext(
+	ctx context.Context,
+	paramIDs sources.Parameters,
+	updater sources.Updater,
+) (initial types.ParamValues, _ error) {
+	r.paramIDs = paramIDs
+	r.updater = updater
+
+	initial, _, err := r.fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.pollInterval <= 0 && r.upstreamChanged == nil {
+		return initial, nil
+	}
+
+	r.started.Store(true)
+	go r.poll()
+
+	return initial, nil
+}
+
+// UpstreamChanged requests the URL to be fetched again, when it is read
+// from a parameter, since the parameter may have changed.
+func (r *httpProvider) UpstreamChanged() {
+	select {
+	case r.upstreamChanged <- struct{}{}:
+	default:
+		// already requested, or the URL is not read from a parameter
+	}
+}
+
+// Reload fetches the URL again.
+func (r *httpProvider) Reload(ctx context.Context) (types.ParamValues, error) {
+	values, _, err := r.fetch(ctx)
+	return values, err
+}
+
+func (r *httpProvider) poll() {
+	defer close(r.done)
+
+	logger := plog.Logger(r.updater.Log)
+
+	// without polling, the URL is only fetched when the parameter with it
+	// changes
+	polling := r.pollInterval > 0
+	failures := 0
+	timer := time.NewTimer(r.pollInterval)
+	if !polling {
+		timer.Stop()
+	}
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-timer.C:
+		case <-r.upstreamChanged:
+		}
+
+		values, changed, err := r.fetch(r.ctx)
+		if err != nil {
+			if r.ctx.Err() != nil {
+				return
+			}
+
+			failures++
+			r.updater.ReportError(err)
+			if !polling {
+				logger.E(fmt.Sprintf("Fetching configuration failed: %v", err))
+				continue
+			}
+
+			delay := r.backoff(failures)
+			logger.E(fmt.Sprintf("Fetching configuration failed, trying again in %v: %v", delay, err))
+			timer.Reset(delay)
+			continue
+		}
+
+		recovered := failures > 0
+		failures = 0
+		if polling {
+			timer.Reset(r.pollInterval)
+		}
+
+		switch {
+		case changed:
+			logger.I("Configuration changed, updating parameters")
+			r.updater.Update(values)
+		case recovered:
+			r.updater.ReportHealthy()
+		}
+	}
+}
+
+// backoff returns how long to wait after the given number of consecutive
+// failures.
+func (r *httpProvider) backoff(failures int) time.Duration {
+	delay := r.pollInterval
+	for range failures {
+		delay *= 2
+		if delay >= r.maxBackoff {
+			return r.maxBackoff
+		}
+	}
+
+	return delay
+}
+
+// fetch requests the URL, returning the values and whether they changed
+// since the last request.
+func (r *httpProvider) fetch(ctx context.Context) (types.ParamValues, bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	u, err := r.urlFn(r.updater)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// the URL may have credentials
+	redacted := u.Redacted()
+	if u.String() != r.url {
+		r.url = u.String()
+		r.etag = ""
+		r.lastModified = ""
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("creating request to %s: %w", redacted, err)
+	}
+
+	req.Header = r.header.Clone()
+	req.Header.Set("Accept", "application/json")
+	if r.etag != "" {
+		req.Header.Set("If-None-Match", r.etag)
+	}
+
+	if r.lastModified != "" {
+		req.Header.Set("If-Modified-Since", r.lastModified)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		// the error from the client includes the URL
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+
+		return nil, false, fmt.Errorf("fetching %s: %w", redacted, err)
+	}
+
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return r.values.Copy(), false, nil
+	case http.StatusOK:
+	default:
+		return nil, false, fmt.Errorf("fetching %s: unexpected status %s", redacted, resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, r.maxBodySize+1))
+	if err != nil {
+		return nil, false, fmt.Errorf("reading response from %s: %w", redacted, err)
+	}
+
+	if int64(len(body)) > r.maxBodySize {
+		return nil, false, fmt.Errorf("response from %s is larger than %d bytes",
+			redacted, r.maxBodySize)
+	}
+
+	values, err := jsonparams.Parse(redacted, body, r.paramIDs)
+	if err != nil {
+		// not recorded, so the content is requested and reported again
+		return values, false, err
+	}
+
+	hash := sha256.Sum256(body)
+	changed := hash != r.hash
+
+	r.etag = resp.Header.Get("ETag")
+	r.lastModified = resp.Header.Get("Last-Modified")
+	r.hash = hash
+	r.values = values
+
+	return values.Copy(), changed, nil
+}
@@ -0,0 +1,244 @@
+//go:build unittest || !integrationtest
+// +build unittest !integrationtest
+
+package cfghttp_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/simplesurance/proteus"
+	"github.com/simplesurance/proteus/internal/assert"
+	"github.com/simplesurance/proteus/sources/cfghttp"
+	"github.com/simplesurance/proteus/sources/cfgtest"
+	"github.com/simplesurance/proteus/types"
+	"github.com/simplesurance/proteus/xtypes"
+)
+
+func TestCfgHTTP(t *testing.T) {
+	server := newConfigServer(`{"name": "app", "http": {"level": 1}}`)
+	defer server.Close()
+
+	serverURL, err := url.Parse(server.URL + "/config?token=abc")
+	assert.NoErrorNow(t, err)
+
+	params := struct {
+		Name string
+		HTTP struct {
+			Level *xtypes.Integer[int]
+		} `param:"http"`
+	}{}
+
+	parsed, err := proteus.MustParse(&params,
+		proteus.WithHistory(10),
+		proteus.WithProviders(cfghttp.New(
+			&xtypes.URL{DefaultValue: serverURL},
+			cfghttp.WithHeader("Authorization", "Bearer abc"),
+			cfghttp.WithPollInterval(10*time.Millisecond))))
+	assert.NoErrorNow(t, err)
+	defer func() {
+		assert.NoError(t, parsed.Stop(context.Background()))
+	}()
+
+	assert.Equal(t, "app", params.Name)
+	assert.Equal(t, 1, params.HTTP.Level.Value())
+	assert.Equal(t, "Bearer abc", server.lastHeader().Get("Authorization"))
+
+	// unchanged content is not requested again
+	assert.Eventually(t, 5*time.Second, func() bool { return server.notModifiedCount() >= 3 }, "conditional requests must be made")
+	assert.Equal(t, 0, len(parsed.History()))
+
+	server.setContent(`{"name": "app", "http": {"level": 2}}`)
+	assert.Eventually(t, 5*time.Second, func() bool { return params.HTTP.Level.Value() == 2 }, "value must be updated")
+
+	// errors are reported, and the provider recovers when they stop
+	server.setStatus(http.StatusInternalServerError)
+	assert.Eventually(t, 5*time.Second, func() bool {
+		return !parsed.Providers()[0].Healthy()
+	}, "provider must report the error")
+
+	server.setStatus(http.StatusOK)
+	assert.Eventually(t, 5*time.Second, func() bool {
+		return parsed.Providers()[0].Healthy()
+	}, "provider must recover")
+
+	assert.Equal(t, 1, len(parsed.History()))
+	assert.Equal(t, 2, params.HTTP.Level.Value())
+}
+
+func TestCfgHTTPFromParam(t *testing.T) {
+	server := newConfigServer(`{"level": 1}`)
+	defer server.Close()
+
+	params := struct {
+		ConfigURL *xtypes.URL `param:"config_url"`
+		Level     *xtypes.Integer[int]
+	}{}
+
+	provider := cfgtest.New(types.ParamValues{
+		"": {"config_url": server.URL},
+	})
+
+	parsed, err := proteus.MustParse(&params,
+		proteus.WithProviders(
+			provider,
+			cfghttp.NewFromParam("", "config_url",
+				cfghttp.WithPollInterval(0))))
+	assert.NoErrorNow(t, err)
+	defer func() {
+		assert.NoError(t, parsed.Stop(context.Background()))
+	}()
+
+	assert.Equal(t, server.URL, params.ConfigURL.Value().String())
+	assert.Equal(t, 1, params.Level.Value())
+
+	// the new URL is fetched when the parameter changes, even without
+	// polling
+	other := newConfigServer(`{"level": 2}`)
+	defer other.Close()
+
+	provider.Update("", "config_url", &other.URL)
+	assert.Eventually(t, 5*time.Second, func() bool { return params.Level.Value() == 2 },
+		"value must be read from the new URL")
+}
+
+func TestCfgHTTPMaxBodySize(t *testing.T) {
+	server := newConfigServer(`{"name": "a name longer than the limit"}`)
+	defer server.Close()
+
+	serverURL, err := url.Parse(server.URL)
+	assert.NoErrorNow(t, err)
+
+	params := struct {
+		Name string
+	}{}
+
+	parsed, err := proteus.MustParse(&params,
+		proteus.WithProviders(cfghttp.New(
+			&xtypes.URL{DefaultValue: serverURL},
+			cfghttp.WithMaxBodySize(16))))
+	assert.ErrorNow(t, err)
+	defer parsed.Stop(context.Background()) //nolint:errcheck
+
+	assert.StringContains(t, err.Error(), "larger than 16 bytes")
+}
+
+func TestCfgHTTPURLOnStruct(t *testing.T) {
+	server := newConfigServer(`{"level": 1}`)
+	defer server.Close()
+
+	params := struct {
+		ConfigURL *xtypes.URL `param:"config_url"`
+		Level     *xtypes.Integer[int]
+	}{}
+	params.ConfigURL = &xtypes.URL{}
+
+	// the URL on the struct is only set after the providers start, so
+	// it can't be used with New
+	parsed, err := proteus.MustParse(&params,
+		proteus.WithProviders(
+			cfgtest.New(types.ParamValues{
+				"": {"config_url": server.URL},
+			}),
+			cfghttp.New(params.ConfigURL, cfghttp.WithPollInterval(0))))
+	assert.ErrorNow(t, err)
+	defer parsed.Stop(context.Background()) //nolint:errcheck
+
+	assert.StringContains(t, err.Error(), "NewFromParam")
+}
+
+func TestCfgHTTPInvalid(t *testing.T) {
+	server := newConfigServer(`{"levle": 1}`)
+	defer server.Close()
+
+	serverURL, err := url.Parse(server.URL)
+	assert.NoErrorNow(t, err)
+
+	params := struct {
+		Level int `param:",optional"`
+	}{}
+
+	parsed, err := proteus.MustParse(&params,
+		proteus.WithProviders(cfghttp.New(&xtypes.URL{DefaultValue: serverURL})))
+	assert.ErrorNow(t, err)
+	defer parsed.Stop(context.Background()) //nolint:errcheck
+
+	assert.StringContains(t, err.Error(), "levle")
+}
+
+// configServer serves a JSON configuration supporting conditional
+// requests.
+type configServer struct {
+	*httptest.Server
+
+	mutex       sync.Mutex
+	content     string
+	status      int
+	version     int
+	header      http.Header
+	notModified int
+}
+
+func newConfigServer(content string) *configServer {
+	ret := &configServer{content: content, status: http.StatusOK}
+	ret.Server = httptest.NewServer(http.HandlerFunc(ret.serveHTTP))
+	return ret
+}
+
+func (s *configServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.header = r.Header.Clone()
+
+	if s.status != http.StatusOK {
+		w.WriteHeader(s.status)
+		return
+	}
+
+	etag := fmt.Sprintf(`"v%d"`, s.version)
+	if r.Header.Get("If-None-Match") == etag {
+		s.notModified++
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write([]byte(s.content))
+}
+
+func (s *configServer) setContent(content string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.content = content
+	s.version++
+}
+
+func (s *configServer) setStatus(status int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.status = status
+}
+
+func (s *configServer) lastHeader() http.Header {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.header
+}
+
+func (s *configServer) notModifiedCount() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.notModified
+}
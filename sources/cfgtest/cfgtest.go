@@ -43,29 +43,37 @@ func (r *TestProvider) IsCommandLineFlag() bool {
 // Update changes a value on the test provider, allowing for test on
 // hot-reloading of parameters.
 func (r *TestProvider) Update(setid, id string, value *string) {
-	valuesCopy := func() types.ParamValues {
-		r.protected.mutex.Lock()
-		defer r.protected.mutex.Unlock()
-
-		set, ok := r.protected.values[setid]
-		if !ok {
-			set = map[string]string{}
-			r.protected.values[setid] = set
-		}
+	r.updater.Update(r.set(setid, id, value))
+}
 
-		if value == nil {
-			delete(set, id)
-			if len(set) == 0 {
-				delete(r.protected.values, setid)
-			}
-		} else {
-			set[id] = *value
-		}
+// UpdateWithResult is the same as Update, but returns the error produced
+// when proteus rejects the update. See sources.Updater for details.
+func (r *TestProvider) UpdateWithResult(setid, id string, value *string) error {
+	return r.updater.UpdateWithResult(r.set(setid, id, value))
+}
 
-		return r.protected.values.Copy()
-	}()
+// set changes a value stored on the provider and returns a copy of all
+// values.
+func (r *TestProvider) set(setid, id string, value *string) types.ParamValues {
+	r.protected.mutex.Lock()
+	defer r.protected.mutex.Unlock()
+
+	set, ok := r.protected.values[setid]
+	if !ok {
+		set = map[string]string{}
+		r.protected.values[setid] = set
+	}
+
+	if value == nil {
+		delete(set, id)
+		if len(set) == 0 {
+			delete(r.protected.values, setid)
+		}
+	} else {
+		set[id] = *value
+	}
 
-	r.updater.Update(valuesCopy)
+	return r.protected.values.Copy()
 }
 
 // Stop does nothing.
@@ -0,0 +1,506 @@
+package cfgtoml
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ParseError describes a syntax error on the file.
+type ParseError struct {
+	Path   string
+	Line   int
+	Column int
+	Msg    string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s:%d:%d: %s", e.Path, e.Line, e.Column, e.Msg)
+}
+
+// entry is a key/value pair read from the file.
+type entry struct {
+	setName string
+	key     string
+	value   string
+	line    int
+}
+
+var (
+	decimalIntRE   = regexp.MustCompile(`^[+-]?(0|[1-9][0-9]*)$`)
+	decimalFloatRE = regexp.MustCompile(`^[+-]?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?$`)
+)
+
+type parser struct {
+	path string
+	data string
+	pos  int
+	line int
+	col  int
+}
+
+// parseDocument parses the file, returning all entries on the order they
+// appear.
+func parseDocument(path string, data []byte) ([]entry, error) {
+	p := &parser{path: path, data: string(data), line: 1, col: 1}
+	if !utf8.ValidString(p.data) {
+		return nil, p.errorf("file is not valid UTF-8")
+	}
+
+	var ret []entry
+	seen := map[string]map[string]bool{"": {}}
+	setName := ""
+
+	for {
+		p.skipBlank(true)
+		c, ok := p.peek()
+		if !ok {
+			return ret, nil
+		}
+
+		if c == '[' {
+			line, col := p.line, p.col
+			p.next()
+			p.skipBlank(false)
+
+			if c, _ := p.peek(); c == '[' {
+				return nil, p.errorf("arrays of tables are not supported")
+			}
+
+			name, err := p.parseKey()
+			if err != nil {
+				return nil, err
+			}
+
+			if name == "" {
+				return nil, &ParseError{p.path, line, col, "section name must not be empty"}
+			}
+
+			p.skipBlank(false)
+			if err := p.expect(']'); err != nil {
+				return nil, err
+			}
+
+			if err := p.expectEndOfLine(); err != nil {
+				return nil, err
+			}
+
+			if _, exists := seen[name]; exists {
+				return nil, &ParseError{p.path, line, col,
+					fmt.Sprintf("section %q is defined more than once", name)}
+			}
+
+			seen[name] = map[string]bool{}
+			setName = name
+			continue
+		}
+
+		line, col := p.line, p.col
+		key, err := p.parseKey()
+		if err != nil {
+			return nil, err
+		}
+
+		p.skipBlank(false)
+		if err := p.expect('='); err != nil {
+			return nil, err
+		}
+
+		p.skipBlank(false)
+		value, _, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+
+		if err := p.expectEndOfLine(); err != nil {
+			return nil, err
+		}
+
+		if seen[setName][key] {
+			return nil, &ParseError{p.path, line, col,
+				fmt.Sprintf("key %q is defined more than once", key)}
+		}
+
+		seen[setName][key] = true
+		ret = append(ret, entry{setName: setName, key: key, value: value, line: line})
+	}
+}
+
+// parseKey parses a bare or quoted key.
+func (p *parser) parseKey() (string, error) {
+	c, ok := p.peek()
+	if !ok {
+		return "", p.errorf("expected a key")
+	}
+
+	var key string
+	switch {
+	case c == '"':
+		var err error
+		key, err = p.parseBasicString()
+		if err != nil {
+			return "", err
+		}
+	case c == '\'':
+		var err error
+		key, err = p.parseLiteralString()
+		if err != nil {
+			return "", err
+		}
+	case isBareKeyChar(c):
+		start := p.pos
+		for c, ok := p.peek(); ok && isBareKeyChar(c); c, ok = p.peek() {
+			p.next()
+		}
+
+		key = p.data[start:p.pos]
+	default:
+		return "", p.errorf("unexpected character %q, expected a key", c)
+	}
+
+	p.skipBlank(false)
+	if c, _ := p.peek(); c == '.' {
+		return "", p.errorf("dotted keys are not supported")
+	}
+
+	return key, nil
+}
+
+// parseValue parses a value, returning it as it must be provided to
+// proteus, and its representation as JSON, used when the value is an
+// element of an array.
+func (p *parser) parseValue() (value, jsonValue string, _ error) {
+	c, ok := p.peek()
+	if !ok {
+		return "", "", p.errorf("expected a value")
+	}
+
+	switch {
+	case c == '"':
+		if strings.HasPrefix(p.data[p.pos:], `"""`) {
+			return "", "", p.errorf("multi-line strings are not supported")
+		}
+
+		s, err := p.parseBasicString()
+		return s, jsonString(s), err
+	case c == '\'':
+		if strings.HasPrefix(p.data[p.pos:], `'''`) {
+			return "", "", p.errorf("multi-line strings are not supported")
+		}
+
+		s, err := p.parseLiteralString()
+		return s, jsonString(s), err
+	case c == '[':
+		s, err := p.parseArray()
+		return s, s, err
+	case c == '{':
+		return "", "", p.errorf("inline tables are not supported")
+	case c == 't' || c == 'f':
+		line, col := p.line, p.col
+		word := p.readWord()
+		if word != "true" && word != "false" {
+			return "", "", &ParseError{p.path, line, col,
+				fmt.Sprintf("invalid value %q", word)}
+		}
+
+		return word, word, nil
+	case c == '+' || c == '-' || (c >= '0' && c <= '9'):
+		line, col := p.line, p.col
+		word := p.readWord()
+		s, err := parseNumber(word)
+		if err != nil {
+			return "", "", &ParseError{p.path, line, col, err.Error()}
+		}
+
+		return s, s, nil
+	default:
+		return "", "", p.errorf("unexpected character %q, expected a value", c)
+	}
+}
+
+// parseArray parses an array, returning it as JSON.
+func (p *parser) parseArray() (string, error) {
+	p.next() // [
+
+	var elements []string
+	for {
+		p.skipBlank(true)
+		c, ok := p.peek()
+		if !ok {
+			return "", p.errorf("unterminated array")
+		}
+
+		if c == ']' {
+			p.next()
+			return "[" + strings.Join(elements, ",") + "]", nil
+		}
+
+		_, element, err := p.parseValue()
+		if err != nil {
+			return "", err
+		}
+
+		elements = append(elements, element)
+
+		p.skipBlank(true)
+		c, ok = p.peek()
+		switch {
+		case ok && c == ',':
+			p.next()
+		case ok && c == ']':
+		default:
+			return "", p.errorf("expected ',' or ']' on array")
+		}
+	}
+}
+
+func (p *parser) parseBasicString() (string, error) {
+	p.next() // "
+
+	var sb strings.Builder
+	for {
+		c, ok := p.peek()
+		if !ok || c == '\n' {
+			return "", p.errorf("unterminated string")
+		}
+
+		p.next()
+		switch {
+		case c == '"':
+			return sb.String(), nil
+		case c == '\\':
+			r, err := p.parseEscape()
+			if err != nil {
+				return "", err
+			}
+
+			sb.WriteRune(r)
+		case c < 0x20 && c != '\t' || c == 0x7f:
+			return "", p.errorf("control characters must be escaped")
+		default:
+			sb.WriteByte(c)
+		}
+	}
+}
+
+func (p *parser) parseEscape() (rune, error) {
+	c, ok := p.peek()
+	if !ok {
+		return 0, p.errorf("unterminated string")
+	}
+
+	line, col := p.line, p.col
+	p.next()
+	switch c {
+	case 'b':
+		return '\b', nil
+	case 't':
+		return '\t', nil
+	case 'n':
+		return '\n', nil
+	case 'f':
+		return '\f', nil
+	case 'r':
+		return '\r', nil
+	case '"':
+		return '"', nil
+	case '\\':
+		return '\\', nil
+	case 'u', 'U':
+		size := 4
+		if c == 'U' {
+			size = 8
+		}
+
+		if p.pos+size > len(p.data) {
+			return 0, &ParseError{p.path, line, col, "invalid unicode escape"}
+		}
+
+		code, err := strconv.ParseUint(p.data[p.pos:p.pos+size], 16, 32)
+		if err != nil || !utf8.ValidRune(rune(code)) {
+			return 0, &ParseError{p.path, line, col, "invalid unicode escape"}
+		}
+
+		for range size {
+			p.next()
+		}
+
+		return rune(code), nil
+	default:
+		return 0, &ParseError{p.path, line, col,
+			fmt.Sprintf("invalid escape sequence \\%c", c)}
+	}
+}
+
+func (p *parser) parseLiteralString() (string, error) {
+	p.next() // '
+
+	start := p.pos
+	for {
+		c, ok := p.peek()
+		if !ok || c == '\n' {
+			return "", p.errorf("unterminated string")
+		}
+
+		if c == '\'' {
+			s := p.data[start:p.pos]
+			p.next()
+			return s, nil
+		}
+
+		p.next()
+	}
+}
+
+// readWord reads characters that can be part of numbers and booleans.
+func (p *parser) readWord() string {
+	start := p.pos
+	for c, ok := p.peek(); ok && (isBareKeyChar(c) || c == '+' || c == '.'); c, ok = p.peek() {
+		p.next()
+	}
+
+	return p.data[start:p.pos]
+}
+
+// skipBlank skips spaces, tabs and comments, and also new lines if
+// newLines is true.
+func (p *parser) skipBlank(newLines bool) {
+	for {
+		c, ok := p.peek()
+		switch {
+		case !ok:
+			return
+		case c == ' ' || c == '\t':
+			p.next()
+		case newLines && (c == '\n' || c == '\r' && p.peekAt(1) == '\n'):
+			p.next()
+		case c == '#':
+			for c, ok := p.peek(); ok && c != '\n' && c != '\r'; c, ok = p.peek() {
+				p.next()
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (p *parser) expectEndOfLine() error {
+	p.skipBlank(false)
+
+	c, ok := p.peek()
+	switch {
+	case !ok:
+		return nil
+	case c == '\n':
+		p.next()
+		return nil
+	case c == '\r' && p.peekAt(1) == '\n':
+		p.next()
+		p.next()
+		return nil
+	default:
+		return p.errorf("unexpected character %q, expected the end of the line", c)
+	}
+}
+
+func (p *parser) expect(c byte) error {
+	got, ok := p.peek()
+	if !ok {
+		return p.errorf("expected %q, found the end of the file", c)
+	}
+
+	if got != c {
+		return p.errorf("expected %q, found %q", c, got)
+	}
+
+	p.next()
+	return nil
+}
+
+func (p *parser) peek() (byte, bool) {
+	if p.pos >= len(p.data) {
+		return 0, false
+	}
+
+	return p.data[p.pos], true
+}
+
+func (p *parser) peekAt(offset int) byte {
+	if p.pos+offset >= len(p.data) {
+		return 0
+	}
+
+	return p.data[p.pos+offset]
+}
+
+func (p *parser) next() {
+	if p.data[p.pos] == '\n' {
+		p.line++
+		p.col = 1
+	} else if utf8.RuneStart(p.data[p.pos]) {
+		// columns count characters, not bytes
+		p.col++
+	}
+
+	p.pos++
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return &ParseError{
+		Path:   p.path,
+		Line:   p.line,
+		Column: p.col,
+		Msg:    fmt.Sprintf(format, args...),
+	}
+}
+
+func isBareKeyChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+		c == '_' || c == '-'
+}
+
+// parseNumber validates an integer or a float, returning how it is
+// provided to proteus. Underscores are removed, and hexadecimal, octal and
+// binary integers are converted to decimal.
+func parseNumber(s string) (string, error) {
+	for i := range len(s) {
+		if s[i] != '_' {
+			continue
+		}
+
+		if i == 0 || i == len(s)-1 || !isDigit(s[i-1]) || !isDigit(s[i+1]) {
+			return "", fmt.Errorf("invalid number %q", s)
+		}
+	}
+
+	clean := strings.ReplaceAll(s, "_", "")
+
+	for prefix, base := range map[string]int{"0x": 16, "0o": 8, "0b": 2} {
+		if digits, ok := strings.CutPrefix(clean, prefix); ok {
+			v, err := strconv.ParseUint(digits, base, 64)
+			if err != nil {
+				return "", fmt.Errorf("invalid number %q", s)
+			}
+
+			return strconv.FormatUint(v, 10), nil
+		}
+	}
+
+	if !decimalIntRE.MatchString(clean) && !decimalFloatRE.MatchString(clean) {
+		return "", fmt.Errorf("invalid number %q", s)
+	}
+
+	return strings.TrimPrefix(clean, "+"), nil
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
+
+func jsonString(s string) string {
+	ret, _ := json.Marshal(s)
+	return string(ret)
+}
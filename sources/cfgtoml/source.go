@@ -0,0 +1,132 @@
+// Package cfgtoml is a parameter provider that reads values from files in a
+// subset of the TOML format, parsed without external dependencies.
+//
+// Keys before the first section are parameters that are not on a set.
+// Sections are parameter sets. For example:
+//
+//	log_level = "info"
+//
+//	[http]
+//	address = ":8080"
+//	max_connections = 64
+//	enabled = true
+//	allowed_origins = ["a.example.com", "b.example.com"]
+//
+// The following is supported:
+//   - comments, starting with "#"
+//   - sections, like "[http]"
+//   - bare and quoted keys
+//   - basic strings, with escape sequences, and literal strings
+//   - integers, including hexadecimal, octal and binary, and floats
+//   - booleans
+//   - arrays, which can span multiple lines; they are provided as JSON,
+//     allowing them to be used with xtypes.RawJSON
+//
+// Multi-line strings, dotted keys, nested sections, inline tables and dates
+// are not supported. Syntax errors are reported with the line and column
+// where they happen, see ParseError.
+//
+// Like cfgenv, all keys and sections must match a parameter or a parameter
+// set of the application, allowing the detection of small mistakes like
+// typos.
+//
+// The file is polled for changes, and parameters are updated when it
+// changes.
+package cfgtoml
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/simplesurance/proteus/internal/fileprovider"
+	"github.com/simplesurance/proteus/sources"
+	"github.com/simplesurance/proteus/types"
+)
+
+// DefaultPollInterval is how often the file is checked for changes, if not
+// specified with WithPollInterval.
+const DefaultPollInterval = 5 * time.Second
+
+// New creates a new provider that reads parameters from the file at path.
+// See package description for details.
+func New(path string, opts ...Option) sources.Provider {
+	ret := &tomlProvider{fileprovider.Provider{
+		Path:         path,
+		PollInterval: DefaultPollInterval,
+		Parse:        parse,
+	}}
+
+	for _, o := range opts {
+		o(ret)
+	}
+
+	return ret
+}
+
+// Option specifies options for the provider.
+type Option func(*tomlProvider)
+
+// WithPollInterval specifies how often the file is checked for changes. If
+// not positive, the file is not watched for changes.
+func WithPollInterval(interval time.Duration) Option {
+	return func(p *tomlProvider) {
+		p.PollInterval = interval
+	}
+}
+
+type tomlProvider struct {
+	fileprovider.Provider
+}
+
+var (
+	_ sources.Reloader       = &tomlProvider{}
+	_ sources.ContextStopper = &tomlProvider{}
+)
+
+func parse(
+	path string,
+	data []byte,
+	paramIDs sources.Parameters,
+) (types.ParamValues, error) {
+	entries, err := parseDocument(path, data)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := types.ParamValues{}
+	var violations types.ErrViolations
+	for _, e := range entries {
+		set, setExists := paramIDs[e.setName]
+		if !setExists {
+			violations = append(violations, types.Violation{
+				Message: fmt.Sprintf(
+					"%s:%d: section %q does not match any expected parameter set",
+					path, e.line, e.setName),
+			})
+			continue
+		}
+
+		if _, ok := set[e.key]; !ok {
+			violations = append(violations, types.Violation{
+				Message: fmt.Sprintf(
+					"%s:%d: key %q does not match any expected application parameter",
+					path, e.line, e.key),
+			})
+			continue
+		}
+
+		values, ok := ret[e.setName]
+		if !ok {
+			values = map[string]string{}
+			ret[e.setName] = values
+		}
+
+		values[e.key] = e.value
+	}
+
+	if len(violations) > 0 {
+		return ret, violations
+	}
+
+	return ret, nil
+}
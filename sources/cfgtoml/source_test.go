@@ -0,0 +1,168 @@
+//go:build unittest || !integrationtest
+// +build unittest !integrationtest
+
+package cfgtoml_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/simplesurance/proteus"
+	"github.com/simplesurance/proteus/internal/assert"
+	"github.com/simplesurance/proteus/internal/testfile"
+	"github.com/simplesurance/proteus/sources/cfgtoml"
+	"github.com/simplesurance/proteus/types"
+	"github.com/simplesurance/proteus/xtypes"
+)
+
+func TestCfgToml(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	testfile.Write(t, path, `# application configuration
+name = "app" # trailing comment
+level = 1
+ratio = 1_000.5e-3
+mask = 0xff
+origins = [
+	"a.example.com",
+	'b.example.com', # literal string
+]
+
+[http]
+address = ":8080\té"
+enabled = true
+"max-conns" = -12
+`)
+
+	params := struct {
+		Name    string
+		Level   *xtypes.Integer[int]
+		Ratio   string
+		Mask    int
+		Origins *xtypes.RawJSON
+		HTTP    struct {
+			Address  string
+			Enabled  bool
+			MaxConns int `param:"max-conns"`
+		} `param:"http"`
+	}{}
+
+	parsed, err := proteus.MustParse(&params,
+		proteus.WithProviders(cfgtoml.New(path,
+			cfgtoml.WithPollInterval(10*time.Millisecond))))
+	assert.NoErrorNow(t, err)
+	defer func() {
+		assert.NoError(t, parsed.Stop(context.Background()))
+	}()
+
+	assert.Equal(t, "app", params.Name)
+	assert.Equal(t, 1, params.Level.Value())
+	assert.Equal(t, "1000.5e-3", params.Ratio)
+	assert.Equal(t, 255, params.Mask)
+	assert.Equal(t, `["a.example.com","b.example.com"]`, string(params.Origins.Value()))
+	assert.Equal(t, ":8080\té", params.HTTP.Address)
+	assert.Equal(t, true, params.HTTP.Enabled)
+	assert.Equal(t, -12, params.HTTP.MaxConns)
+
+	// changes are applied to xtypes
+	testfile.Write(t, path, `name = "app"
+level = 2
+ratio = 1.0005
+mask = 255
+origins = []
+
+[http]
+address = ":8080"
+enabled = true
+max-conns = -12
+`)
+
+	assert.Eventually(t, 2*time.Second, func() bool { return params.Level.Value() == 2 }, "value must be updated")
+	assert.Equal(t, `[]`, string(params.Origins.Value()))
+
+	// invalid changes are ignored
+	testfile.Write(t, path, `level = 3
+level = 4
+`)
+	assert.Eventually(t, 2*time.Second, func() bool {
+		return !parsed.Providers()[0].Healthy()
+	}, "provider must report the error")
+	assert.Equal(t, 2, params.Level.Value())
+}
+
+func TestCfgTomlUnknownKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	testfile.Write(t, path, `name = "app"
+nmae = "typo"
+
+[http]
+adress = ":80"
+
+[htpp]
+address = ":80"
+`)
+
+	params := struct {
+		Name string
+		HTTP struct {
+			Address string `param:",optional"`
+		} `param:"http"`
+	}{}
+
+	parsed, err := proteus.MustParse(&params,
+		proteus.WithProviders(cfgtoml.New(path, cfgtoml.WithPollInterval(0))))
+	assert.ErrorNow(t, err)
+	defer parsed.Stop(context.Background()) //nolint:errcheck
+
+	var violations types.ErrViolations
+	assert.TrueNow(t, errors.As(err, &violations), "error must be violations")
+	assert.Equal(t, 3, len(violations))
+	assert.StringContains(t, err.Error(), path+":2:")
+	assert.StringContains(t, err.Error(), path+":5:")
+	assert.StringContains(t, err.Error(), path+":8:")
+}
+
+func TestCfgTomlSyntaxError(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		line    int
+		column  int
+	}{
+		{"unterminated string", "name = \"app\n", 1, 12},
+		{"missing value", "name =\n", 1, 7},
+		{"invalid number", "name = 1_\n", 1, 8},
+		{"invalid boolean", "\n  name = tru\n", 2, 10},
+		{"multi-byte characters", "name = \"é\" x\n", 1, 12},
+		{"duplicated key", "[s]\na = 1\n a = 2\n", 3, 2},
+		{"duplicated section", "[s]\n[s]\n", 2, 1},
+		{"dotted key", "a.b = 1\n", 1, 2},
+		{"inline table", "a = {}\n", 1, 5},
+		{"unterminated array", "a = [1,\n2\n", 3, 1},
+		{"invalid escape", `a = "\x"`, 1, 7},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.toml")
+			testfile.Write(t, path, tc.content)
+
+			params := struct {
+				Name string `param:",optional"`
+			}{}
+
+			parsed, err := proteus.MustParse(&params,
+				proteus.WithProviders(cfgtoml.New(path)))
+			assert.ErrorNow(t, err)
+			defer parsed.Stop(context.Background()) //nolint:errcheck
+
+			var parseErr *cfgtoml.ParseError
+			assert.TrueNow(t, errors.As(err, &parseErr), "error must be ParseError: "+err.Error())
+			assert.Equal(t, path, parseErr.Path)
+			assert.Equal(t, tc.line, parseErr.Line)
+			assert.Equal(t, tc.column, parseErr.Column)
+		})
+	}
+}
@@ -2,6 +2,10 @@
 package sources
 
 import (
+	"context"
+	"reflect"
+	"strings"
+
 	"github.com/simplesurance/proteus/plog"
 	"github.com/simplesurance/proteus/types"
 )
@@ -29,6 +33,120 @@ type Provider interface {
 	IsCommandLineFlag() bool
 }
 
+// ContextWatcher is an optional interface for providers that need a context
+// while starting, for example to limit how long they wait for a remote
+// service to respond. When a provider implements it, proteus calls
+// WatchContext instead of Watch.
+//
+// The context only limits the startup of the provider: it can be canceled
+// after WatchContext returns, and must not be used by goroutines that
+// continue watching for changes.
+type ContextWatcher interface {
+	WatchContext(
+		ctx context.Context,
+		paramIDs Parameters,
+		updater Updater,
+	) (initial types.ParamValues, err error)
+}
+
+// ContextStopper is an optional interface for providers that start
+// goroutines. When a provider implements it, proteus calls StopContext
+// instead of Stop.
+type ContextStopper interface {
+	// StopContext stops the provider and waits until all its goroutines
+	// terminate, or until the context is done, in which case the error
+	// from the context is returned.
+	StopContext(ctx context.Context) error
+}
+
+// SourceNamer is an optional interface for providers, allowing them to
+// specify how they are identified when restricting from where parameters
+// can be read, for example with the "sources" option of the "param" tag.
+// Providers that do not implement it are identified by the name of their
+// package without the "cfg" prefix; for example, cfgenv is identified as
+// "env" and cfgflags as "flags".
+type SourceNamer interface {
+	SourceName() string
+}
+
+// SourceName returns the name that identifies the provider when restricting
+// from where parameters can be read, see SourceNamer. The name is
+// normalized with NormalizeSourceName.
+func SourceName(provider Provider) string {
+	if namer, ok := provider.(SourceNamer); ok {
+		return NormalizeSourceName(namer.SourceName())
+	}
+
+	typ := reflect.TypeOf(provider)
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	pkgPath := typ.PkgPath()
+	return NormalizeSourceName(pkgPath[strings.LastIndex(pkgPath, "/")+1:])
+}
+
+// NormalizeSourceName returns the name of a source in the form returned by
+// SourceName, lowercase and without the "cfg" prefix, so names provided by
+// users can be compared with it.
+func NormalizeSourceName(name string) string {
+	return strings.TrimPrefix(strings.ToLower(name), "cfg")
+}
+
+// Scrubber is an optional interface for providers that read values from
+// places other processes can read, like environment variables, which are
+// inherited by child processes. It is used to remove secrets from those
+// places after they are read. See proteus.WithSecretScrubbing.
+type Scrubber interface {
+	// Scrub removes the values of the specified parameters from the
+	// source, and returns where each removed value was stored. The
+	// provider must keep providing the removed values, for example when
+	// reloading.
+	Scrub(paramIDs Parameters) ([]ScrubbedValue, error)
+}
+
+// ScrubbedValue describes a value removed by Scrubber.Scrub.
+type ScrubbedValue struct {
+	SetName   string
+	ParamName string
+
+	// Location describes where the value was stored, like the name of an
+	// environment variable.
+	Location string
+}
+
+// UpstreamObserver is an optional interface for providers configured by
+// values read with Updater.Peek, allowing them to know when those values
+// may have changed.
+type UpstreamObserver interface {
+	// UpstreamChanged is called after an update from one of the providers
+	// registered before this one is accepted. It must not block; the
+	// provider should read the new values with Peek on another goroutine.
+	// When the configuration is reloaded, it is not called; providers
+	// should implement Reloader instead, since their Reload method is
+	// called after the providers before them are reloaded, and Peek
+	// returns the reloaded values. It is only called if the reloaded
+	// configuration is rejected, since Peek then returns the previous
+	// values again.
+	UpstreamChanged()
+}
+
+// Reloader is an optional interface that providers can implement to allow
+// proteus to request them to read their configuration source again. This
+// is useful for providers that do not watch their source for changes, like
+// environment variables.
+//
+// Reloading is requested by calling Parsed.Reload(), or automatically when
+// the application receives one of the signals specified with the
+// WithReloadOnSignal option.
+type Reloader interface {
+	// Reload reads the configuration source again and returns all found
+	// parameters. It is only called after Watch returned successfully.
+	// When an error is returned, the values previously provided by the
+	// provider are kept.
+	Reload(ctx context.Context) (types.ParamValues, error)
+}
+
 // Updater is an interface that has as its primary use allowing providers to
 // notify proteus about changes in parameter values.
 //
@@ -36,14 +154,28 @@ type Provider interface {
 // one of them, and it is passed to the respective provider as a parameter of
 // `Watch`. When the provider calls the Update() method on its own updater,
 // that will result in updating the copy of the parameter values for that
-// provider.
+// provider. Only xtypes on the configuration struct are updated with values
+// received after parsing; other parameters keep the values read on
+// startup.
 //
 // This updater also allows providers to produce log messages.
 type Updater interface {
 	// Update notify about a change in parameter values.
 	// Useful only for providers that support hot-updating values.
+	// The update is rejected if it results in an invalid configuration;
+	// use UpdateWithResult to know if that happened. When the application
+	// enables debouncing, the update may be applied later, coalesced with
+	// other updates from the same provider.
 	Update(types.ParamValues)
 
+	// UpdateWithResult is the same as Update, but reports if the update
+	// was rejected. When rejected, the values previously provided by the
+	// provider are kept, and the returned error describes the reason.
+	// When the reason is an invalid configuration, the error is
+	// types.ErrViolations. This allows providers to, for example, mark a
+	// revision of the configuration as bad.
+	UpdateWithResult(types.ParamValues) error
+
 	// Log allows the provider to use the logger from the parser.
 	// All log entries will be identified with the class name of the
 	// provider.
@@ -52,7 +184,19 @@ type Updater interface {
 	// Peek reads the raw parameter value from the providers registered
 	// before the provider associated to this updater. This allow one
 	// provider to be configured by values received by other providers.
+	// See also UpstreamObserver.
 	Peek(setName, paramName string) (*string, error)
+
+	// ReportError allows the provider to inform that something is not
+	// working as expected, for example, that the configuration source
+	// can't be reached. The error is made available to the application
+	// as part of the provider status.
+	ReportError(error)
+
+	// ReportHealthy allows the provider to inform that the problems
+	// reported with ReportError were resolved. Providing values that are
+	// accepted also marks the provider as healthy.
+	ReportHealthy()
 }
 
 // Parameters contains information that proteus makes available to providers
@@ -74,4 +218,8 @@ func (p Parameters) Get(setName, paramName string) (ret ParameterInfo, found boo
 // made available to a configuration provider.
 type ParameterInfo struct {
 	IsBool bool
+
+	// IsSecret indicates that the value of the parameter is a secret, that
+	// the provider must not log or include in errors.
+	IsSecret bool
 }
@@ -0,0 +1,115 @@
+package proteus
+
+import "github.com/simplesurance/proteus/internal/paramvalues"
+
+// ParamState describes the current state of a parameter, including from
+// where its value comes from. Values are redacted.
+type ParamState struct {
+	SetName   string
+	ParamName string
+
+	// Type describes the type of the parameter, as shown on usage
+	// information.
+	Type string
+
+	// Description is the description provided with the "param_desc"
+	// tag.
+	Description string
+
+	// Optional is true when the parameter is optional.
+	Optional bool
+
+	// Value is the redacted effective value of the parameter.
+	Value string
+
+	// IsDefault is true when no provider provides a value for the
+	// parameter, and the default value is being used.
+	IsDefault bool
+
+	// Provider is the name of the provider that provides the value
+	// being used. It is empty when the default value is being used.
+	Provider string
+
+	// Secret is true when the parameter is marked as secret.
+	Secret bool
+
+	// Dynamic is true when the parameter is an xtype, which is updated
+	// without restarting the application.
+	Dynamic bool
+
+	// PendingRestart is true when the parameter is not dynamic, and
+	// the value provided for it changed after the application started.
+	// The new value is only used after the application is restarted.
+	PendingRestart bool
+
+	// OverrideExpireError is set when the override of the parameter (see
+	// Parsed.Override) expired, but could not be removed because the
+	// configuration without it is invalid. Removing it is retried.
+	OverrideExpireError error
+}
+
+// State returns the current state of all parameters, sorted by set and
+// parameter name. Values are redacted.
+func (p *Parsed) State() []ParamState {
+	p.protected.valuesMutex.Lock()
+	defer p.protected.valuesMutex.Unlock()
+
+	in := p.valuesInterpolator()
+
+	var ret []ParamState
+	for _, setName := range mapKeysSorted(p.inferedConfig) {
+		set := p.inferedConfig[setName]
+
+		for _, paramName := range mapKeysSorted(set.fields) {
+			param := set.fields[paramName]
+
+			state := ParamState{
+				SetName:     setName,
+				ParamName:   paramName,
+				Type:        param.typ,
+				Description: param.desc,
+				Optional:    param.optional,
+				Secret:      param.secret,
+				Dynamic:     param.isXtype,
+			}
+
+			value, providerName := p.desiredValueAndProvider(in, setName, paramName)
+			if value == nil {
+				state.IsDefault = true
+				state.Value = param.redactedDefaultValue()
+			} else {
+				state.Provider = providerName
+				state.Value = param.redactedValue(value)()
+			}
+
+			if !param.isXtype && p.protected.startupValues != nil {
+				startup := p.protected.startupValues.Get(setName, paramName)
+				state.PendingRestart = !paramvalues.EqualValue(startup, value)
+			}
+
+			state.OverrideExpireError = p.protected.overrides.expireError(setName, paramName)
+
+			ret = append(ret, state)
+		}
+	}
+
+	return ret
+}
+
+// desiredValueAndProvider is the same as desiredValue, but also returns the
+// name of the provider that provides the value.
+// Caller must hold the mutex.
+func (p *Parsed) desiredValueAndProvider(in *interpolator, setName, paramName string) (*string, string) {
+	if value := p.protected.overrides.get(setName, paramName); value != nil {
+		return in.expanded(setName, paramName, value), overrideProviderName
+	}
+
+	for ix, providerData := range p.protected.values {
+		if value := providerData.Get(setName, paramName); value != nil {
+			return in.expanded(setName, paramName, value),
+				p.updaters[ix].provenance()
+		}
+	}
+
+	return nil, ""
+}
@@ -0,0 +1,181 @@
+package proteus
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ProviderStatus describes the state of a configuration provider, allowing
+// applications to, for example, use it on readiness probes.
+type ProviderStatus struct {
+	// Name identifies the provider.
+	Name string
+
+	// Priority is the position of the provider on the list of providers.
+	// Values from providers with a lower priority value take precedence.
+	Priority int
+
+	// LastUpdate is when the provider last provided values that were
+	// accepted. It is zero if it never happened.
+	LastUpdate time.Time
+
+	// LastError is the last error reported by the provider, or the reason
+	// why the last update from it was rejected. It is nil if the provider
+	// reported to be healthy after that, or if values from it were
+	// accepted after that.
+	LastError error
+
+	// LastErrorTime is when LastError happened.
+	LastErrorTime time.Time
+
+	// RejectedUpdates is how many updates from the provider were rejected.
+	RejectedUpdates int
+
+	// UnknownParams are the parameters that the application did not
+	// register, dropped from the last update received from the provider,
+	// as "set.param", or "param" for parameters not on a set. Only set
+	// with UnknownParamIgnore and UnknownParamWarn.
+	UnknownParams []string
+
+	// FromLastKnownGood is true when the provider failed to start and the
+	// values being used for it were read from the last-known-good cache.
+	// It becomes false when the provider sends values that are accepted.
+	// See WithLastKnownGood.
+	FromLastKnownGood bool
+
+	// LastKnownGoodTime is when the values being used were stored on the
+	// last-known-good cache. Only set when FromLastKnownGood is true.
+	LastKnownGoodTime time.Time
+}
+
+// Healthy returns true if the provider has no pending error.
+func (s ProviderStatus) Healthy() bool {
+	return s.LastError == nil
+}
+
+// Providers returns the status of all configuration providers, ordered by
+// priority.
+func (p *Parsed) Providers() []ProviderStatus {
+	ret := make([]ProviderStatus, 0, len(p.updaters))
+	for _, updater := range p.updaters {
+		if updater == nil {
+			continue
+		}
+
+		ret = append(ret, updater.providerStatus())
+	}
+
+	return ret
+}
+
+// updaterStatus holds the information needed to generate the ProviderStatus
+// of a provider.
+type updaterStatus struct {
+	mutex           sync.Mutex
+	lastUpdate      time.Time
+	lastError       error
+	lastErrorTime   time.Time
+	rejectedUpdates int
+	unknownParams   []string
+
+	// lastKnownGoodTime is set when the values being used were read from
+	// the last-known-good cache
+	lastKnownGoodTime time.Time
+}
+
+// ReportError allows the provider to inform that something is not working
+// as expected.
+func (u *updater) ReportError(err error) {
+	u.status.mutex.Lock()
+	defer u.status.mutex.Unlock()
+
+	u.status.lastError = err
+	u.status.lastErrorTime = time.Now()
+}
+
+// ReportHealthy allows the provider to inform that the problems reported
+// with ReportError were resolved.
+func (u *updater) ReportHealthy() {
+	u.status.mutex.Lock()
+	defer u.status.mutex.Unlock()
+
+	u.status.lastError = nil
+}
+
+// updateAccepted records that values from the provider were accepted.
+func (u *updater) updateAccepted() {
+	u.status.mutex.Lock()
+	defer u.status.mutex.Unlock()
+
+	u.status.lastUpdate = time.Now()
+	u.status.lastError = nil
+	u.status.lastKnownGoodTime = time.Time{}
+}
+
+// startedFromLastKnownGood records that the provider failed to start with
+// err, and that values stored at savedAt on the last-known-good cache are
+// being used instead.
+func (u *updater) startedFromLastKnownGood(err error, savedAt time.Time) {
+	u.status.mutex.Lock()
+	defer u.status.mutex.Unlock()
+
+	u.status.lastError = err
+	u.status.lastErrorTime = time.Now()
+	u.status.lastKnownGoodTime = savedAt
+}
+
+func (u *updater) fromLastKnownGood() bool {
+	u.status.mutex.Lock()
+	defer u.status.mutex.Unlock()
+
+	return !u.status.lastKnownGoodTime.IsZero()
+}
+
+// provenance returns the name used to identify the provider as the source
+// of a value.
+func (u *updater) provenance() string {
+	if u.fromLastKnownGood() {
+		return u.providerName + lastKnownGoodProviderSuffix
+	}
+
+	return u.providerName
+}
+
+// unknownParamsDropped records the unknown parameters dropped from the last
+// update.
+func (u *updater) unknownParamsDropped(ids []string) {
+	u.status.mutex.Lock()
+	defer u.status.mutex.Unlock()
+
+	u.status.unknownParams = ids
+}
+
+// updateRejected records that values from the provider were rejected.
+func (u *updater) updateRejected(err error) {
+	u.status.mutex.Lock()
+	defer u.status.mutex.Unlock()
+
+	u.status.lastError = fmt.Errorf("update rejected: %w", err)
+	u.status.lastErrorTime = time.Now()
+	u.status.rejectedUpdates++
+}
+
+func (u *updater) providerStatus() ProviderStatus {
+	u.status.mutex.Lock()
+	defer u.status.mutex.Unlock()
+
+	return ProviderStatus{
+		Name:            u.providerName,
+		Priority:        u.providerIndex,
+		LastUpdate:      u.status.lastUpdate,
+		LastError:       u.status.lastError,
+		LastErrorTime:   u.status.lastErrorTime,
+		RejectedUpdates: u.status.rejectedUpdates,
+		UnknownParams:   slices.Clone(u.status.unknownParams),
+
+		FromLastKnownGood: !u.status.lastKnownGoodTime.IsZero(),
+		LastKnownGoodTime: u.status.lastKnownGoodTime,
+	}
+}
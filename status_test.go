@@ -0,0 +1,54 @@
+//go:build unittest || !integrationtest
+// +build unittest !integrationtest
+
+package proteus_test
+
+import (
+	"testing"
+
+	"github.com/simplesurance/proteus"
+	"github.com/simplesurance/proteus/internal/assert"
+	"github.com/simplesurance/proteus/sources/cfgtest"
+	"github.com/simplesurance/proteus/types"
+	"github.com/simplesurance/proteus/xtypes"
+)
+
+func TestProviderStatus(t *testing.T) {
+	params := struct {
+		Level *xtypes.Integer[int]
+	}{}
+
+	provider1 := cfgtest.New(types.ParamValues{})
+	provider2 := cfgtest.New(types.ParamValues{
+		"": {"level": "1"},
+	})
+
+	parsed, err := proteus.MustParse(&params, proteus.WithProviders(provider1, provider2))
+	assert.NoErrorNow(t, err)
+	defer stop(t, parsed)
+
+	status := parsed.Providers()
+	assert.EqualNow(t, 2, len(status))
+	for ix, s := range status {
+		assert.Equal(t, ix, s.Priority)
+		assert.Equal(t, "*cfgtest.TestProvider", s.Name)
+		assert.True(t, s.Healthy(), "provider must be healthy")
+		assert.True(t, !s.LastUpdate.IsZero(), "last update must be set")
+	}
+
+	err = provider2.UpdateWithResult("", "level", ptr("invalid"))
+	assert.ErrorNow(t, err)
+
+	status = parsed.Providers()
+	assert.True(t, status[0].Healthy(), "provider 1 must be healthy")
+	assert.True(t, !status[1].Healthy(), "provider 2 must not be healthy")
+	assert.Equal(t, 0, status[0].RejectedUpdates)
+	assert.Equal(t, 1, status[1].RejectedUpdates)
+
+	err = provider2.UpdateWithResult("", "level", ptr("2"))
+	assert.NoErrorNow(t, err)
+
+	status = parsed.Providers()
+	assert.True(t, status[1].Healthy(), "provider 2 must be healthy after a successful update")
+	assert.Equal(t, 1, status[1].RejectedUpdates)
+}
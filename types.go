@@ -30,7 +30,8 @@ func (c config) paramInfo(includeSpecial bool) sources.Parameters {
 			}
 
 			paramIDs[paramName] = sources.ParameterInfo{
-				IsBool: info.boolean,
+				IsBool:   info.boolean,
+				IsSecret: info.secret,
 			}
 		}
 
@@ -58,11 +59,19 @@ type paramSetField struct {
 	// providers, like --help or --version.
 	isSpecial bool
 
+	// sources are the names of the sources from where the parameter can
+	// be read; nil if there is no restriction
+	sources []string
+
 	isXtype      bool // implements the types.XType interface
 	setValueFn   func(v *string) error
 	validFn      func(v string) error
 	getDefaultFn func() (string, error)
 	redactFn     func(string) string
+
+	// valueFn reads the value from the configuration struct; nil when
+	// not available, like for special parameters
+	valueFn func() any
 }
 
 func (f paramSetField) redactedValue(v *string) func() string {
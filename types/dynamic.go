@@ -54,6 +54,15 @@ type Redactor interface {
 	RedactValue(string) string
 }
 
+// SecretMarker is implemented by xtypes that always hold secret values, like
+// xtypes.Secret. Parameters of these types are handled as if they were
+// marked with the "secret" option. Since the value of these types should
+// not be easily reachable, they are not required to implement "Value() T".
+type SecretMarker interface {
+	// IsSecret reports if values of the type are secret.
+	IsSecret() bool
+}
+
 // TypeDescriber overrides the "type" of a parameter when showing usage
 // information to the user. When an xtype does not implement this interface,
 // the return type of the Value() function is used. Implementing this function
@@ -2,6 +2,7 @@ package proteus
 
 import (
 	"fmt"
+	"slices"
 
 	"github.com/simplesurance/proteus/plog"
 	"github.com/simplesurance/proteus/sources"
@@ -20,15 +21,68 @@ type updater struct {
 	providerName  string
 
 	updatesEnabled chan struct{} // close this to allow updates
+
+	// debounce coalesces updates from the provider; nil when updates
+	// are applied immediately
+	debounce *debouncer
+
+	// lastKnownGood caches the values from the provider; nil when
+	// caching is disabled
+	lastKnownGood *lastKnownGood
+
+	status updaterStatus
 }
 
 var _ sources.Updater = &updater{}
 
 func (u *updater) Update(v types.ParamValues) {
+	if u.debounce != nil {
+		// do not block the provider, so further updates can be
+		// coalesced; rejected updates are logged when applied
+		u.debounce.submit(v)
+		return
+	}
+
+	// rejected updates are already logged
+	_ = u.UpdateWithResult(v)
+}
+
+func (u *updater) UpdateWithResult(v types.ParamValues) error {
+	if u.debounce != nil {
+		return <-u.debounce.submit(v)
+	}
+
+	return u.apply(v)
+}
+
+// apply validates and applies the values received from the provider.
+func (u *updater) apply(v types.ParamValues) error {
 	// this is for proteus to delay updates until everything gets initialized
-	<-u.updatesEnabled
+	select {
+	case <-u.updatesEnabled:
+	case <-u.parsed.lifecycle.stopped:
+		return errStopped
+	}
+
+	if !u.parsed.beginOperation() {
+		return errStopped
+	}
+	defer u.parsed.endOperation()
+
+	err := u.update(v, true)
+	if err != nil {
+		u.parsed.settings.loggerFn.E(fmt.Sprintf(
+			"provider %q update rejected: %v", u.providerName, err))
+	}
+
+	return err
+}
 
-	u.update(v, true)
+// sourceName returns the name of the source of the provider, see
+// sources.SourceNamer. It is read on each update, since providers that
+// wrap other providers may change it.
+func (u *updater) sourceName() string {
+	return sources.SourceName(u.parsed.settings.providers[u.providerIndex])
 }
 
 func (u *updater) Log(entry plog.Entry) {
@@ -44,7 +98,11 @@ func (u *updater) Peek(setName, paramName string) (*string, error) {
 		return nil, fmt.Errorf("first provider can't peek values from providers before it")
 	}
 
-	for _, provData := range u.parsed.protected.values[:u.providerIndex] {
+	for ix, provData := range u.parsed.protected.values[:u.providerIndex] {
+		if reloaded, ok := u.parsed.protected.reloading[ix]; ok {
+			provData = reloaded
+		}
+
 		ret := provData.Get(setName, paramName)
 		if ret != nil {
 			return ret, nil
@@ -54,26 +112,96 @@ func (u *updater) Peek(setName, paramName string) (*string, error) {
 	return nil, nil
 }
 
-func (u *updater) update(v types.ParamValues, refresh bool) {
-	v = v.Copy()
+// update stores the values provided by the provider. If the values can't be
+// accepted, the previous values are kept and an error is returned. When
+// refresh is true, the configuration struct is also updated, and the values
+// are rejected if the resulting configuration is invalid.
+func (u *updater) update(v types.ParamValues, refresh bool) error {
+	if refresh {
+		u.parsed.settings.metrics.UpdateReceived(u.providerName)
+	}
 
-	for _, set := range v {
-		for paramName, paramValue := range set {
-			set[paramName] = u.parsed.settings.valueFormatting.apply(paramValue)
+	previous := u.parsed.providerValues(u.providerIndex)
+
+	stored, err := u.store(v, refresh)
+	if refresh {
+		u.parsed.recordUpdate(u.providerName, previous, stored, err)
+	}
+
+	if err != nil {
+		u.updateRejected(err)
+		return err
+	}
+
+	if refresh {
+		u.updateAccepted()
+		u.parsed.notifyUpstreamChanged(u.providerIndex)
+	}
+
+	return nil
+}
+
+// notifyUpstreamChanged informs the providers registered after the one at
+// index ix that values they can read with Peek changed.
+func (p *Parsed) notifyUpstreamChanged(ix int) {
+	for _, provider := range p.settings.providers[ix+1:] {
+		if observer, ok := provider.(sources.UpstreamObserver); ok {
+			observer.UpstreamChanged()
 		}
 	}
+}
 
-	u.mustBeOnValidIDs(v)
-	u.validateValues(v)
+// store formats and stores the values, returning the values that were
+// stored, or would have been stored if they were not rejected.
+func (u *updater) store(v types.ParamValues, refresh bool) (types.ParamValues, error) {
+	v, err := u.prepare(v)
+	if err != nil {
+		return v, err
+	}
+
+	// runs after the mutex is released
+	defer u.parsed.saveLastKnownGood()
 
 	u.parsed.protected.valuesMutex.Lock()
 	defer u.parsed.protected.valuesMutex.Unlock()
 
+	previous := u.parsed.protected.values[u.providerIndex]
 	u.parsed.protected.values[u.providerIndex] = v
 
-	if refresh {
-		u.parsed.refresh(false) // update only dynamic parameters
+	if !refresh {
+		return v, nil
+	}
+
+	// update only dynamic parameters
+	if err := u.parsed.refresh(false); err != nil {
+		u.parsed.protected.values[u.providerIndex] = previous
+		return v, err
 	}
+
+	return v, nil
+}
+
+// prepare returns a formatted copy of the values, rejecting them if they
+// are not acceptable from the provider. The values are not stored.
+func (u *updater) prepare(v types.ParamValues) (types.ParamValues, error) {
+	v = v.Copy()
+
+	for _, set := range v {
+		for paramName, paramValue := range set {
+			set[paramName] = u.parsed.settings.valueFormatting.apply(paramValue)
+		}
+	}
+
+	if err := u.removeUnknownIDs(v); err != nil {
+		return v, err
+	}
+
+	if err := u.checkSources(v); err != nil {
+		return v, err
+	}
+
+	u.validateValues(v)
+	return v, nil
 }
 
 func (u *updater) validateValues(v types.ParamValues) {
@@ -89,18 +217,72 @@ func (u *updater) validateValues(v types.ParamValues) {
 	}
 }
 
-// mustBeOnValidIDs panics if one of the values provided by the provided is
-// not on the list of ids the application registered.
-func (u *updater) mustBeOnValidIDs(v types.ParamValues) {
+// removeUnknownIDs handles values provided by the provider for parameters
+// that the application did not register, according to the configured
+// UnknownParamPolicy. Unknown values are either removed from v, or reported
+// as violations.
+func (u *updater) removeUnknownIDs(v types.ParamValues) error {
+	var violations types.ErrViolations
 	for setName, set := range v {
-		if _, ok := u.parsed.inferedConfig[setName]; !ok {
-			panic(fmt.Errorf("parameter source is providing value for unsolicited paramset %q", setName))
-		}
+		_, setExists := u.parsed.inferedConfig[setName]
 
 		for paramName := range set {
-			if _, ok := u.parsed.inferedConfig[setName].fields[paramName]; !ok {
-				panic(fmt.Errorf("parameter source is providing value for unsolicited parameter %s.%s", setName, paramName))
+			if setExists {
+				if _, ok := u.parsed.inferedConfig[setName].fields[paramName]; ok {
+					continue
+				}
 			}
+
+			msg := "parameter is not expected by the application"
+			if !setExists {
+				msg = "parameter set is not expected by the application"
+			}
+
+			violations = append(violations, types.Violation{
+				SetName:   setName,
+				ParamName: paramName,
+				Message:   fmt.Sprintf("%s (provided by %s)", msg, u.providerName),
+			})
 		}
 	}
+
+	policy := u.parsed.settings.unknownParamPolicy
+	if len(violations) == 0 {
+		if policy != UnknownParamReject {
+			u.unknownParamsDropped(nil)
+		}
+
+		return nil
+	}
+
+	switch policy {
+	case UnknownParamIgnore, UnknownParamWarn:
+		dropped := make([]string, 0, len(violations))
+		for _, violation := range violations {
+			msg := "Ignoring value: " + violation.String()
+			if policy == UnknownParamWarn {
+				u.parsed.settings.loggerFn.W(msg)
+			} else {
+				u.parsed.settings.loggerFn.D(msg)
+			}
+
+			id := violation.ParamName
+			if violation.SetName != "" {
+				id = violation.SetName + "." + id
+			}
+
+			dropped = append(dropped, id)
+
+			delete(v[violation.SetName], violation.ParamName)
+			if len(v[violation.SetName]) == 0 {
+				delete(v, violation.SetName)
+			}
+		}
+
+		slices.Sort(dropped)
+		u.unknownParamsDropped(dropped)
+		return nil
+	default:
+		return violations
+	}
 }
@@ -0,0 +1,158 @@
+//go:build unittest || !integrationtest
+// +build unittest !integrationtest
+
+package proteus_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/simplesurance/proteus"
+	"github.com/simplesurance/proteus/internal/assert"
+	"github.com/simplesurance/proteus/plog"
+	"github.com/simplesurance/proteus/sources/cfgtest"
+	"github.com/simplesurance/proteus/types"
+	"github.com/simplesurance/proteus/xtypes"
+)
+
+func TestUnknownParamPolicyReject(t *testing.T) {
+	params := struct {
+		Name string
+	}{}
+
+	provider := cfgtest.New(types.ParamValues{
+		"":        {"name": "test", "nmae": "typo"},
+		"unknown": {"param": "value"},
+	})
+
+	_, err := proteus.MustParse(&params, proteus.WithProviders(provider))
+	assert.ErrorNow(t, err)
+
+	var violations types.ErrViolations
+	assert.TrueNow(t, errors.As(err, &violations), "error must be violations")
+	assert.Equal(t, 2, len(violations))
+}
+
+func TestUnknownParamPolicyIgnore(t *testing.T) {
+	params := struct {
+		Name string
+	}{}
+
+	provider := cfgtest.New(types.ParamValues{
+		"":        {"name": "test", "nmae": "typo"},
+		"unknown": {"param": "value"},
+	})
+
+	parsed, err := proteus.MustParse(&params,
+		proteus.WithLogger(plog.TestLogger(t)),
+		proteus.WithUnknownParamPolicy(proteus.UnknownParamIgnore),
+		proteus.WithProviders(provider))
+	assert.NoErrorNow(t, err)
+	assert.Equal(t, "test", params.Name)
+	defer stop(t, parsed)
+
+	assert.Equal(t, "nmae,unknown.param",
+		strings.Join(parsed.Providers()[0].UnknownParams, ","))
+}
+
+func TestUnknownParamPolicyWarn(t *testing.T) {
+	params := struct {
+		Name *xtypes.String
+	}{}
+
+	provider := cfgtest.New(types.ParamValues{
+		"": {"name": "test", "nmae": "typo"},
+	})
+
+	var warnings int
+	parsed, err := proteus.MustParse(&params,
+		proteus.WithLogger(func(e plog.Entry) {
+			if e.Severity == plog.SevWarning {
+				warnings++
+			}
+			t.Log(e.Message)
+		}),
+		proteus.WithUnknownParamPolicy(proteus.UnknownParamWarn),
+		proteus.WithProviders(provider))
+	assert.NoErrorNow(t, err)
+	defer stop(t, parsed)
+
+	assert.Equal(t, "test", params.Name.Value())
+	assert.Equal(t, 1, warnings)
+	assert.Equal(t, "nmae", strings.Join(parsed.Providers()[0].UnknownParams, ","))
+
+	// an update without unknown parameters clears them from the status
+	provider.Update("", "nmae", nil)
+	assert.Equal(t, 0, len(parsed.Providers()[0].UnknownParams))
+}
+
+// TestUnknownParamOnUpdate asserts that providing an unknown parameter on
+// an update does not crash the application, and that the update is rejected
+// as a whole.
+func TestUnknownParamOnUpdate(t *testing.T) {
+	params := struct {
+		Name *xtypes.String
+	}{}
+
+	provider := cfgtest.New(types.ParamValues{
+		"": {"name": "initial"},
+	})
+
+	var loggedErrors int
+	parsed, err := proteus.MustParse(&params,
+		proteus.WithLogger(func(e plog.Entry) {
+			if e.Severity == plog.SevError {
+				loggedErrors++
+			}
+			t.Log(e.Message)
+		}),
+		proteus.WithProviders(provider))
+	assert.NoErrorNow(t, err)
+	defer stop(t, parsed)
+
+	provider.Update("", "name", ptr("updated"))
+	assert.Equal(t, "updated", params.Name.Value())
+
+	// from now on, all updates from the provider include an unknown
+	// parameter; none of them must be applied
+	provider.Update("", "unknown", ptr("value"))
+	provider.Update("", "name", ptr("not applied"))
+	assert.Equal(t, "updated", params.Name.Value())
+	assert.Equal(t, 2, loggedErrors)
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestUpdateWithResult(t *testing.T) {
+	params := struct {
+		Level *xtypes.Integer[int]
+	}{}
+
+	provider := cfgtest.New(types.ParamValues{
+		"": {"level": "1"},
+	})
+
+	parsed, err := proteus.MustParse(&params, proteus.WithProviders(provider))
+	assert.NoErrorNow(t, err)
+	defer stop(t, parsed)
+
+	err = provider.UpdateWithResult("", "level", ptr("2"))
+	assert.NoErrorNow(t, err)
+	assert.Equal(t, 2, params.Level.Value())
+
+	err = provider.UpdateWithResult("", "level", ptr("invalid"))
+	assert.ErrorNow(t, err)
+
+	var violations types.ErrViolations
+	assert.TrueNow(t, errors.As(err, &violations), "error must be violations")
+	assert.Equal(t, "level", violations[0].ParamName)
+	assert.Equal(t, 2, params.Level.Value())
+
+	// the rejected value must have been discarded
+	buf := strings.Builder{}
+	parsed.Dump(&buf)
+	assert.StringContains(t, buf.String(), `- level = "2"`)
+}
@@ -13,7 +13,8 @@ const invalidValue = "<invalid>"
 // parameter value. This is determine by:
 //   - the value belonging to a type that implements the unmarshaller interface
 //   - the value belonging to a type that implements a method Value() T, where
-//     the type T any supported value.
+//     the type T any supported value. Types implementing types.SecretMarker
+//     are not required to implement it.
 func isXType(ty reflect.Type) (bool, error) {
 	tyUnmarshaler := reflect.TypeOf((*types.XType)(nil)).Elem()
 	if !ty.AssignableTo(tyUnmarshaler) {
@@ -21,6 +22,10 @@ func isXType(ty reflect.Type) (bool, error) {
 	}
 
 	valueMethod, ok := ty.MethodByName("Value")
+	if !ok && ty.AssignableTo(reflect.TypeOf((*types.SecretMarker)(nil)).Elem()) {
+		return true, nil
+	}
+
 	if !ok {
 		return false, fmt.Errorf("provided XType is incorrectly implemented: missing 'Value() T' method")
 	}
@@ -67,6 +72,13 @@ func toXType(val reflect.Value) types.XType {
 	return val.Interface().(types.XType)
 }
 
+// isSecretXType returns true if the xtype holds values that are always
+// secret.
+func isSecretXType(val reflect.Value) bool {
+	marker, ok := val.Interface().(types.SecretMarker)
+	return ok && marker.IsSecret()
+}
+
 func toRedactor(val reflect.Value) types.Redactor {
 	if ret, ok := val.Interface().(types.Redactor); ok {
 		return ret
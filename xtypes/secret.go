@@ -0,0 +1,129 @@
+package xtypes
+
+import (
+	"log/slog"
+	"strconv"
+	"sync"
+
+	"github.com/simplesurance/proteus/internal/consts"
+	"github.com/simplesurance/proteus/types"
+)
+
+// Secret is an xtype for strings that must not be leaked, like passwords
+// and tokens. Parameters of this type are always handled as secret, even if
+// not marked with the "secret" option.
+//
+// Formatting the value with the fmt package, marshaling it to JSON or text,
+// or logging it with log/slog only produces a redacted placeholder. The
+// value can only be read with Reveal.
+type Secret struct {
+	DefaultValue string
+
+	// UpdateFn is called when the value changes, allowing, for example,
+	// to rotate credentials without restarting the application. Use
+	// Reveal to read the new value.
+	UpdateFn   func(*Secret)
+	ValidateFn func(string) error
+	content    struct {
+		value *string
+		mutex sync.Mutex
+	}
+}
+
+var (
+	_ types.XType         = &Secret{}
+	_ types.Redactor      = &Secret{}
+	_ types.TypeDescriber = &Secret{}
+	_ types.SecretMarker  = &Secret{}
+	_ slog.LogValuer      = &Secret{}
+)
+
+// UnmarshalParam stores the input as the value of the secret.
+func (d *Secret) UnmarshalParam(in *string) error {
+	var ptrStr *string
+	if in != nil {
+		strValue := *in // copy
+		ptrStr = &strValue
+	}
+
+	d.content.mutex.Lock()
+	d.content.value = ptrStr
+	d.content.mutex.Unlock()
+
+	if d.UpdateFn != nil {
+		d.UpdateFn(d)
+	}
+
+	return nil
+}
+
+// Reveal reads the current updated value, taking the default value into
+// consideration. This is the only way of reading the value.
+func (d *Secret) Reveal() string {
+	d.content.mutex.Lock()
+	defer d.content.mutex.Unlock()
+
+	if d.content.value == nil {
+		return d.DefaultValue
+	}
+
+	return *d.content.value
+}
+
+// ValueValid test if the provided parameter value is valid. Has no side
+// effects.
+func (d *Secret) ValueValid(v string) error {
+	if d.ValidateFn == nil {
+		return nil
+	}
+
+	return d.ValidateFn(v)
+}
+
+// GetDefaultValue will be used to read the default value when showing usage
+// information.
+func (d *Secret) GetDefaultValue() (string, error) {
+	return d.DefaultValue, nil
+}
+
+// IsSecret returns true, indicating that parameters of this type are always
+// secret.
+func (d *Secret) IsSecret() bool {
+	return true
+}
+
+// DescribeType describes the type of the parameter on usage information.
+func (d *Secret) DescribeType() string {
+	return "string"
+}
+
+// RedactValue redacts the whole value.
+func (d *Secret) RedactValue(string) string {
+	return consts.RedactedPlaceholder
+}
+
+// String returns the redacted placeholder.
+func (d *Secret) String() string {
+	return consts.RedactedPlaceholder
+}
+
+// GoString returns the redacted placeholder, also when formatted with "%#v".
+func (d *Secret) GoString() string {
+	return consts.RedactedPlaceholder
+}
+
+// MarshalJSON returns the redacted placeholder as a JSON string.
+func (d *Secret) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(consts.RedactedPlaceholder)), nil
+}
+
+// MarshalText returns the redacted placeholder.
+func (d *Secret) MarshalText() ([]byte, error) {
+	return []byte(consts.RedactedPlaceholder), nil
+}
+
+// LogValue returns the redacted placeholder when the secret is logged with
+// log/slog.
+func (d *Secret) LogValue() slog.Value {
+	return slog.StringValue(consts.RedactedPlaceholder)
+}
@@ -0,0 +1,95 @@
+//go:build unittest || !integrationtest
+// +build unittest !integrationtest
+
+package xtypes_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/simplesurance/proteus"
+	"github.com/simplesurance/proteus/internal/assert"
+	"github.com/simplesurance/proteus/sources/cfgtest"
+	"github.com/simplesurance/proteus/types"
+	"github.com/simplesurance/proteus/xtypes"
+)
+
+func TestSecret(t *testing.T) {
+	const secretValue = "hunter2"
+
+	var rotated []string
+	params := struct {
+		Token *xtypes.Secret
+	}{
+		Token: &xtypes.Secret{
+			UpdateFn: func(s *xtypes.Secret) {
+				rotated = append(rotated, s.Reveal())
+			},
+		},
+	}
+
+	provider := cfgtest.New(types.ParamValues{
+		"": map[string]string{"token": secretValue},
+	})
+
+	parsed, err := proteus.MustParse(&params, proteus.WithProviders(provider))
+	assert.NoErrorNow(t, err)
+
+	assert.Equal(t, secretValue, params.Token.Reveal())
+
+	// the type is always secret, even without the "secret" option
+	buffer := bytes.Buffer{}
+	parsed.Dump(&buffer)
+	assert.True(t, !strings.Contains(buffer.String(), secretValue), "dump must not leak the secret")
+
+	for _, state := range parsed.State() {
+		if state.ParamName == "token" {
+			assert.True(t, state.Secret, "parameter must be secret")
+		}
+	}
+
+	buffer = bytes.Buffer{}
+	parsed.Usage(&buffer)
+	assert.StringContains(t, buffer.String(), "-token <string>")
+
+	// none of the usual ways of printing the value leak it
+	outputs := []string{
+		fmt.Sprintf("%v", params),
+		fmt.Sprintf("%+v", params),
+		fmt.Sprintf("%#v", params.Token),
+		fmt.Sprintf("%s", params.Token),
+	}
+
+	jsonData, err := json.Marshal(params)
+	assert.NoErrorNow(t, err)
+	outputs = append(outputs, string(jsonData))
+
+	text, err := params.Token.MarshalText()
+	assert.NoErrorNow(t, err)
+	outputs = append(outputs, string(text))
+
+	logBuffer := bytes.Buffer{}
+	slog.New(slog.NewTextHandler(&logBuffer, nil)).Info("config", "token", params.Token)
+	outputs = append(outputs, logBuffer.String())
+
+	for _, out := range outputs {
+		assert.True(t, !strings.Contains(out, secretValue), "value leaked: "+out)
+	}
+
+	assert.Equal(t, `{"Token":"REDACTED"}`, string(jsonData))
+
+	// rotation
+	provider.Update("", "token", ptr("rotated"))
+	assert.Equal(t, "rotated", params.Token.Reveal())
+	assert.EqualNow(t, 2, len(rotated))
+	assert.Equal(t, secretValue, rotated[0])
+	assert.Equal(t, "rotated", rotated[1])
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}